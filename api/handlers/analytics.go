@@ -88,6 +88,15 @@ func (h *AnalyticsHandler) GetModelAnalytics(c *gin.Context) {
 	c.JSON(http.StatusOK, analytics)
 }
 
+func (h *AnalyticsHandler) GetCacheAnalytics(c *gin.Context) {
+	analytics, err := h.analyticsService.GetCacheAnalytics()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, analytics)
+}
+
 func (h *AnalyticsHandler) GetSystemHealth(c *gin.Context) {
 	health, err := h.analyticsService.GetSystemHealth()
 	if err != nil {
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"llm-inferra/internal/api/middleware"
 	"llm-inferra/internal/models"
@@ -58,7 +59,13 @@ func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
 
 	apiKey, err := h.apiKeyService.Create(userID, req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		} else if strings.Contains(err.Error(), "invalid") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -66,19 +73,42 @@ func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
 }
 
 func (h *APIKeyHandler) GetAPIKey(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	apiKey, ok := h.authorizeAPIKey(c)
+	if !ok {
+		return
+	}
 
+	c.JSON(http.StatusOK, apiKey)
+}
+
+// authorizeAPIKey loads the key named by the route and checks that it belongs to the caller,
+// admins may manage every key. Writes the error response and returns false otherwise.
+func (h *APIKeyHandler) authorizeAPIKey(c *gin.Context) (*models.APIKey, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
 	apiKey, err := h.apiKeyService.GetByID(uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return nil, false
 	}
 
-	c.JSON(http.StatusOK, apiKey)
+	if apiKey.UserID != userID && !middleware.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return apiKey, true
 }
 
 func (h *APIKeyHandler) UpdateAPIKey(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	existing, ok := h.authorizeAPIKey(c)
+	if !ok {
+		return
+	}
 
 	var apiKey models.APIKey
 	if err := c.ShouldBindJSON(&apiKey); err != nil {
@@ -86,8 +116,34 @@ func (h *APIKeyHandler) UpdateAPIKey(c *gin.Context) {
 		return
 	}
 
-	if err := h.apiKeyService.Update(uint(id), &apiKey); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.apiKeyService.Update(existing.ID, &apiKey); err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		} else if strings.Contains(err.Error(), "invalid") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, apiKey)
+}
+
+// RevokeAPIKey disables a key for good without touching the upstream credentials behind it
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	existing, ok := h.authorizeAPIKey(c)
+	if !ok {
+		return
+	}
+
+	apiKey, err := h.apiKeyService.Revoke(existing.ID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -95,9 +151,12 @@ func (h *APIKeyHandler) UpdateAPIKey(c *gin.Context) {
 }
 
 func (h *APIKeyHandler) DeleteAPIKey(c *gin.Context) {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	existing, ok := h.authorizeAPIKey(c)
+	if !ok {
+		return
+	}
 
-	if err := h.apiKeyService.Delete(uint(id)); err != nil {
+	if err := h.apiKeyService.Delete(existing.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"llm-inferra/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Embeddings handles POST /v1/embeddings
+func (h *LLMHandler) Embeddings(c *gin.Context) {
+	// Extract API key
+	apiKey := h.extractAPIKey(c)
+	if apiKey == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"message": "API key is required",
+				"type":    "authentication_error",
+			},
+		})
+		return
+	}
+
+	// Validate API key and get context
+	ctx, err := h.llmService.ValidateAPIKeyOptimized(apiKey)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"message": err.Error(),
+				"type":    "authentication_error",
+			},
+		})
+		return
+	}
+
+	// Parse request body
+	var req models.EmbeddingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": fmt.Sprintf("Invalid request body: %v", err),
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	response, err := h.llmService.Embeddings(ctx, &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	setRateLimitHeaders(c, ctx)
+	if err != nil {
+		openAIError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"llm-inferra/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// llmError is how a service error of the LLM endpoints is reported: the HTTP status, and the
+// error type in the OpenAI and in the Anthropic format
+type llmError struct {
+	Status        int
+	OpenAIType    string
+	AnthropicType string
+}
+
+// classifyLLMError maps a service error onto its status and error types. The chat, embeddings
+// and messages endpoints all use it, so they report the same error the same way.
+func classifyLLMError(err error) llmError {
+	// Upstream errors carry the provider's body, which must not be matched as our own message
+	var upstreamErr *services.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return classifyUpstreamError(upstreamErr.StatusCode)
+	}
+
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "invalid") || strings.Contains(message, "validation"):
+		return llmError{http.StatusBadRequest, "invalid_request_error", "invalid_request_error"}
+	case strings.Contains(message, "cost limit exceeded"):
+		return llmError{http.StatusTooManyRequests, "insufficient_quota", "rate_limit_error"}
+	case strings.Contains(message, "limit exceeded"):
+		return llmError{http.StatusTooManyRequests, "rate_limit_error", "rate_limit_error"}
+	case strings.Contains(message, "not found"):
+		return llmError{http.StatusNotFound, "invalid_request_error", "not_found_error"}
+	case strings.Contains(message, "temporarily unavailable"):
+		return llmError{http.StatusServiceUnavailable, "api_error", "overloaded_error"}
+	case strings.Contains(message, "permission denied"):
+		return llmError{http.StatusForbidden, "permission_error", "permission_error"}
+	default:
+		return llmError{http.StatusInternalServerError, "api_error", "api_error"}
+	}
+}
+
+// classifyUpstreamError maps the status of a failed upstream call. Rate limits and overload are
+// passed on so clients back off; failures of the provider or of the gateway's own upstream
+// credentials (401/403) are the gateway's problem, not the client's, and become 502.
+func classifyUpstreamError(status int) llmError {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return llmError{http.StatusTooManyRequests, "rate_limit_error", "rate_limit_error"}
+	case status == http.StatusServiceUnavailable || status == 529:
+		return llmError{http.StatusServiceUnavailable, "api_error", "overloaded_error"}
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || status >= 500:
+		return llmError{http.StatusBadGateway, "api_error", "api_error"}
+	case status == http.StatusNotFound:
+		return llmError{http.StatusNotFound, "invalid_request_error", "not_found_error"}
+	default:
+		// The provider rejected the request itself
+		return llmError{http.StatusBadRequest, "invalid_request_error", "invalid_request_error"}
+	}
+}
+
+// openAIError writes a service error in the OpenAI error format
+func openAIError(c *gin.Context, err error) {
+	classified := classifyLLMError(err)
+	c.JSON(classified.Status, gin.H{
+		"error": gin.H{
+			"message": err.Error(),
+			"type":    classified.OpenAIType,
+		},
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"llm-inferra/internal/services"
+)
+
+func TestClassifyLLMError(t *testing.T) {
+	tests := []struct {
+		err       string
+		status    int
+		openAI    string
+		anthropic string
+	}{
+		{"request validation failed: model is required", http.StatusBadRequest, "invalid_request_error", "invalid_request_error"},
+		{"daily cost limit exceeded", http.StatusTooManyRequests, "insufficient_quota", "rate_limit_error"},
+		{"rate limit exceeded", http.StatusTooManyRequests, "rate_limit_error", "rate_limit_error"},
+		{"model not found", http.StatusNotFound, "invalid_request_error", "not_found_error"},
+		{"provider temporarily unavailable", http.StatusServiceUnavailable, "api_error", "overloaded_error"},
+		{"permission denied for model", http.StatusForbidden, "permission_error", "permission_error"},
+		{"connection reset", http.StatusInternalServerError, "api_error", "api_error"},
+	}
+
+	for _, tt := range tests {
+		got := classifyLLMError(errors.New(tt.err))
+		if got.Status != tt.status || got.OpenAIType != tt.openAI || got.AnthropicType != tt.anthropic {
+			t.Errorf("classifyLLMError(%q) = %+v", tt.err, got)
+		}
+	}
+}
+
+func TestClassifyUpstreamError(t *testing.T) {
+	tests := []struct {
+		upstream  *services.UpstreamError
+		status    int
+		openAI    string
+		anthropic string
+	}{
+		// A broken gateway credential is not the client's fault, whatever the body says
+		{&services.UpstreamError{StatusCode: 401, Body: `{"error":{"message":"invalid x-api-key"}}`}, http.StatusBadGateway, "api_error", "api_error"},
+		{&services.UpstreamError{StatusCode: 403, Body: `permission denied`}, http.StatusBadGateway, "api_error", "api_error"},
+		{&services.UpstreamError{StatusCode: 429, Type: "rate_limit_error", Body: `rate limit exceeded`}, http.StatusTooManyRequests, "rate_limit_error", "rate_limit_error"},
+		{&services.UpstreamError{StatusCode: 529, Type: "overloaded_error"}, http.StatusServiceUnavailable, "api_error", "overloaded_error"},
+		{&services.UpstreamError{StatusCode: 503}, http.StatusServiceUnavailable, "api_error", "overloaded_error"},
+		{&services.UpstreamError{StatusCode: 500, Body: `model not found`}, http.StatusBadGateway, "api_error", "api_error"},
+		{&services.UpstreamError{StatusCode: 400, Body: `bad`}, http.StatusBadRequest, "invalid_request_error", "invalid_request_error"},
+	}
+
+	for _, tt := range tests {
+		// Wrapped like the service errors that reach the handlers
+		got := classifyLLMError(fmt.Errorf("upstream call failed: %w", tt.upstream))
+		if got.Status != tt.status || got.OpenAIType != tt.openAI || got.AnthropicType != tt.anthropic {
+			t.Errorf("upstream %d: classified as %+v", tt.upstream.StatusCode, got)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"llm-inferra/internal/models"
 	"llm-inferra/internal/services"
@@ -83,6 +85,12 @@ func (h *LLMHandler) ChatCompletion(c *gin.Context) {
 	clientIP := c.ClientIP()
 	userAgent := c.GetHeader("User-Agent")
 
+	// Stream format may also be selected via header for clients that cannot change the body
+	if req.StreamFormat == "" {
+		req.StreamFormat = c.GetHeader("X-Stream-Format")
+	}
+	ctx.NoResponseCache = skipResponseCache(c)
+
 	// Handle streaming vs non-streaming
 	if req.Stream {
 		h.handleStreamingCompletion(c, ctx, &req, clientIP, userAgent)
@@ -94,32 +102,14 @@ func (h *LLMHandler) ChatCompletion(c *gin.Context) {
 func (h *LLMHandler) handleRegularCompletion(c *gin.Context, ctx *models.LLMRequestContext, req *models.ChatCompletionRequest, clientIP, userAgent string) {
 	// Make the completion request
 	response, err := h.llmService.ChatCompletion(ctx, req, clientIP, userAgent)
+	setRateLimitHeaders(c, ctx)
 	if err != nil {
-		// Determine error type and status code
-		statusCode := http.StatusInternalServerError
-		errorType := "api_error"
-
-		if strings.Contains(err.Error(), "invalid") || strings.Contains(err.Error(), "validation") {
-			statusCode = http.StatusBadRequest
-			errorType = "invalid_request_error"
-		} else if strings.Contains(err.Error(), "limit exceeded") {
-			statusCode = http.StatusTooManyRequests
-			errorType = "rate_limit_error"
-		} else if strings.Contains(err.Error(), "not found") {
-			statusCode = http.StatusNotFound
-			errorType = "invalid_request_error"
-		}
-
-		c.JSON(statusCode, gin.H{
-			"error": gin.H{
-				"message": err.Error(),
-				"type":    errorType,
-			},
-		})
+		openAIError(c, err)
 		return
 	}
 
 	// Return successful response
+	setServedByHeaders(c, ctx)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -131,33 +121,34 @@ func (h *LLMHandler) handleStreamingCompletion(c *gin.Context, ctx *models.LLMRe
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("Access-Control-Allow-Headers", "Cache-Control")
 
-	// Get streaming response
+	// Get streaming response, which stops forwarding once the client has gone away
+	ctx.Done = c.Request.Context().Done()
 	streamChan, err := h.llmService.StreamChatCompletion(ctx, req, clientIP, userAgent)
+	setRateLimitHeaders(c, ctx)
 	if err != nil {
 		// Send error as SSE event
-		errorType := "api_error"
-		if strings.Contains(err.Error(), "invalid") || strings.Contains(err.Error(), "validation") {
-			errorType = "invalid_request_error"
-		} else if strings.Contains(err.Error(), "limit exceeded") {
-			errorType = "rate_limit_error"
-		}
-
+		errorType := classifyLLMError(err).OpenAIType
 		errorEvent := fmt.Sprintf("data: {\"error\": {\"message\": \"%s\", \"type\": \"%s\"}}\n\n", err.Error(), errorType)
 		c.String(http.StatusOK, errorEvent)
 		return
 	}
 
+	setServedByHeaders(c, ctx)
+
 	// Stream the response
 	for {
 		select {
 		case data, ok := <-streamChan:
 			if !ok {
-				// Stream closed, send final event
-				c.SSEvent("", "[DONE]")
+				// Stream closed, send the OpenAI terminator. Native Anthropic streams end with message_stop
+				if req.StreamFormat != models.StreamFormatAnthropic {
+					c.Writer.Write([]byte("data: [DONE]\n\n"))
+					c.Writer.Flush()
+				}
 				return
 			}
 
-			// Forward the data as-is (Anthropic sends proper SSE format)
+			// Forward the data as-is (adapters already emit SSE formatted events)
 			c.Writer.Write(data)
 			c.Writer.Flush()
 		case <-c.Request.Context().Done():
@@ -167,6 +158,53 @@ func (h *LLMHandler) handleStreamingCompletion(c *gin.Context, ctx *models.LLMRe
 	}
 }
 
+// setServedByHeaders reports the provider and model that actually served the request,
+// which differ from the requested ones when a fallback was used
+func setServedByHeaders(c *gin.Context, ctx *models.LLMRequestContext) {
+	if ctx.Provider != nil {
+		c.Header("X-Inferra-Provider", ctx.Provider.Name)
+	}
+	if ctx.Model != nil {
+		c.Header("X-Inferra-Model", ctx.Model.ModelID)
+	}
+	if ctx.ResponseCacheStatus != "" {
+		c.Header("X-Inferra-Cache", ctx.ResponseCacheStatus)
+	}
+}
+
+// skipResponseCache reports whether the client asked for a fresh response with
+// Cache-Control: no-cache
+func skipResponseCache(c *gin.Context) bool {
+	return strings.Contains(strings.ToLower(c.GetHeader("Cache-Control")), "no-cache")
+}
+
+// setRateLimitHeaders reports the tightest requests and tokens per minute limit in the OpenAI
+// x-ratelimit-* headers, on rejected requests too
+func setRateLimitHeaders(c *gin.Context, ctx *models.LLMRequestContext) {
+	status := ctx.RateLimit
+	if status == nil {
+		return
+	}
+	if status.LimitRequests > 0 {
+		c.Header("x-ratelimit-limit-requests", strconv.Itoa(status.LimitRequests))
+		c.Header("x-ratelimit-remaining-requests", strconv.Itoa(status.RemainingRequests))
+		c.Header("x-ratelimit-reset-requests", formatRateLimitReset(status.ResetRequests))
+	}
+	if status.LimitTokens > 0 {
+		c.Header("x-ratelimit-limit-tokens", strconv.Itoa(status.LimitTokens))
+		c.Header("x-ratelimit-remaining-tokens", strconv.Itoa(status.RemainingTokens))
+		c.Header("x-ratelimit-reset-tokens", formatRateLimitReset(status.ResetTokens))
+	}
+}
+
+// formatRateLimitReset formats a reset time like OpenAI does (1s, 6m0s, 20ms)
+func formatRateLimitReset(reset time.Duration) string {
+	if reset < 0 {
+		reset = 0
+	}
+	return reset.Round(time.Millisecond).String()
+}
+
 // Models endpoint - list available models
 func (h *LLMHandler) ListModels(c *gin.Context) {
 	// Extract API key
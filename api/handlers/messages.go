@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"llm-inferra/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Messages handles POST /v1/messages (native Anthropic Messages API)
+func (h *LLMHandler) Messages(c *gin.Context) {
+	// Extract API key (Anthropic SDKs send x-api-key)
+	apiKey := h.extractAPIKey(c)
+	if apiKey == "" {
+		messagesError(c, http.StatusUnauthorized, "authentication_error", "API key is required")
+		return
+	}
+
+	// Validate API key and get context
+	ctx, err := h.llmService.ValidateAPIKeyOptimized(apiKey)
+	if err != nil {
+		messagesError(c, http.StatusUnauthorized, "authentication_error", err.Error())
+		return
+	}
+
+	// Parse request body
+	var req models.MessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		messagesError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	// Get client information
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+	ctx.NoResponseCache = skipResponseCache(c)
+
+	if req.Stream {
+		h.handleStreamingMessages(c, ctx, &req, clientIP, userAgent)
+		return
+	}
+
+	response, err := h.llmService.Messages(ctx, &req, clientIP, userAgent)
+	setRateLimitHeaders(c, ctx)
+	if err != nil {
+		classified := classifyLLMError(err)
+		messagesError(c, classified.Status, classified.AnthropicType, err.Error())
+		return
+	}
+
+	setServedByHeaders(c, ctx)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *LLMHandler) handleStreamingMessages(c *gin.Context, ctx *models.LLMRequestContext, req *models.MessagesRequest, clientIP, userAgent string) {
+	// Start the stream before writing SSE headers so errors are returned as regular HTTP errors.
+	// It stops forwarding once the client has gone away.
+	ctx.Done = c.Request.Context().Done()
+	streamChan, err := h.llmService.StreamMessages(ctx, req, clientIP, userAgent)
+	setRateLimitHeaders(c, ctx)
+	if err != nil {
+		classified := classifyLLMError(err)
+		messagesError(c, classified.Status, classified.AnthropicType, err.Error())
+		return
+	}
+
+	setServedByHeaders(c, ctx)
+
+	// Set headers for Server-Sent Events
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	// Events are already in the Messages format; the stream ends with message_stop, no [DONE]
+	for {
+		select {
+		case data, ok := <-streamChan:
+			if !ok {
+				return
+			}
+			c.Writer.Write(data)
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			// Client disconnected
+			return
+		}
+	}
+}
+
+// messagesError writes an error in the Anthropic error format
+func messagesError(c *gin.Context, statusCode int, errorType, message string) {
+	c.JSON(statusCode, gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    errorType,
+			"message": message,
+		},
+	})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"llm-inferra/internal/models"
 	"llm-inferra/internal/services"
@@ -123,3 +124,97 @@ func (h *ProviderHandler) UpdateModel(c *gin.Context) {
 func (h *ProviderHandler) DeleteModel(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Model deleted"})
 }
+
+func (h *ProviderHandler) GetModelFallbacks(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	fallbacks, err := h.providerService.GetModelFallbacks(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"fallbacks": fallbacks})
+}
+
+func (h *ProviderHandler) SetModelFallbacks(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var req models.SetModelFallbacksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fallbacks, err := h.providerService.SetModelFallbacks(uint(id), req.FallbackModelIDs)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		} else if strings.Contains(err.Error(), "invalid") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"fallbacks": fallbacks})
+}
+
+func (h *ProviderHandler) ListCredentials(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	credentials, err := h.providerService.ListCredentials(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"credentials": credentials})
+}
+
+func (h *ProviderHandler) CreateCredential(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	var req models.CreateCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, err := h.providerService.CreateCredential(uint(id), req)
+	if err != nil {
+		c.JSON(credentialErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, credential)
+}
+
+func (h *ProviderHandler) UpdateCredential(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	credentialID, _ := strconv.ParseUint(c.Param("credentialId"), 10, 32)
+	var req models.UpdateCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, err := h.providerService.UpdateCredential(uint(id), uint(credentialID), req)
+	if err != nil {
+		c.JSON(credentialErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, credential)
+}
+
+func (h *ProviderHandler) DeleteCredential(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+	credentialID, _ := strconv.ParseUint(c.Param("credentialId"), 10, 32)
+	if err := h.providerService.DeleteCredential(uint(id), uint(credentialID)); err != nil {
+		c.JSON(credentialErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Credential deleted"})
+}
+
+func credentialErrorStatus(err error) int {
+	if strings.Contains(err.Error(), "not found") {
+		return http.StatusNotFound
+	} else if strings.Contains(err.Error(), "invalid") {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -75,13 +75,23 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	return true
 }
 
+// Limiter decides whether one more request may go through for a key
+type Limiter interface {
+	Allow(key string) bool
+}
+
+// RateLimit limits each client IP to requestsPerSecond with a per-process limiter
 func RateLimit(requestsPerSecond int) gin.HandlerFunc {
 	limiter := NewRateLimiter(requestsPerSecond)
+	limiter.StartCleanup()
 
-	return func(c *gin.Context) {
-		ip := c.ClientIP()
+	return RateLimitWith(limiter, KeyByIP)
+}
 
-		if !limiter.Allow(ip) {
+// RateLimitWith limits requests per key with any limiter, see KeyBy for the keys
+func RateLimitWith(limiter Limiter, keyFunc KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Allow(keyFunc(c)) {
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "Rate limit exceeded",
 				"retry_after": 1,
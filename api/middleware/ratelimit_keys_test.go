@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"llm-inferra/internal/secrets"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestKeyByUserResolvesBeforeAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const secret = "test-secret"
+	owner := func(keyHash string) (uint, bool) {
+		if keyHash == secrets.Digest("sk-inferra-known") {
+			return 42, true
+		}
+		return 0, false
+	}
+	keyFunc := KeyByUser(secret, owner)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   string
+	}{
+		{"jwt", "Authorization", "Bearer " + token, "user:7"},
+		{"api key", "Authorization", "Bearer sk-inferra-known", "user:42"},
+		{"x-api-key", "x-api-key", "sk-inferra-known", "user:42"},
+		{"unknown key", "Authorization", "Bearer sk-inferra-unknown", "ip:192.0.2.1"},
+		{"anonymous", "", "", "ip:192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		if tt.header != "" {
+			c.Request.Header.Set(tt.header, tt.value)
+		}
+
+		if got := keyFunc(c); got != tt.want {
+			t.Errorf("%s: key = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// gcraScript applies the generic cell rate algorithm to one key. The key holds the theoretical
+// arrival time (TAT) in microseconds of Redis server time, so replicas share one clock. A request
+// is allowed while the TAT stays within one period of now, which allows bursts of the full rate.
+//
+// KEYS[1] bucket, ARGV[1] emission interval (µs), ARGV[2] period (µs)
+var gcraScript = redis.NewScript(`
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local interval = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if tat == nil or tat < now then
+	tat = now
+end
+
+local newTat = tat + interval
+if newTat - now > period then
+	return 0
+end
+
+redis.call('SET', KEYS[1], newTat, 'PX', math.ceil((newTat - now) / 1000))
+return 1
+`)
+
+// redisTimeout bounds each limiter round trip, a slow Redis must not slow every request down
+const redisTimeout = 50 * time.Millisecond
+
+// RedisRateLimiter shares a requests per second limit across all gateway instances. When Redis
+// can't be reached it falls back to the per-process limiter until Redis answers again.
+type RedisRateLimiter struct {
+	client   *redis.Client
+	prefix   string
+	interval time.Duration
+	period   time.Duration
+	fallback *RateLimiter
+	degraded atomic.Bool
+}
+
+func NewRedisRateLimiter(client *redis.Client, requestsPerSecond int) *RedisRateLimiter {
+	fallback := NewRateLimiter(requestsPerSecond)
+	fallback.StartCleanup()
+
+	period := time.Second
+	interval := period
+	if requestsPerSecond > 0 {
+		interval = period / time.Duration(requestsPerSecond)
+	}
+
+	return &RedisRateLimiter{
+		client:   client,
+		prefix:   "ratelimit:",
+		interval: interval,
+		period:   period,
+		fallback: fallback,
+	}
+}
+
+func (rl *RedisRateLimiter) Allow(key string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	allowed, err := gcraScript.Run(ctx, rl.client, []string{rl.prefix + key},
+		rl.interval.Microseconds(), rl.period.Microseconds()).Int()
+	if err != nil {
+		if !rl.degraded.Swap(true) {
+			// TODO: Replace with proper logger
+			fmt.Printf("Redis rate limiter unavailable, using per-instance limits: %v\n", err)
+		}
+		return rl.fallback.Allow(key)
+	}
+
+	if rl.degraded.Swap(false) {
+		// TODO: Replace with proper logger
+		fmt.Printf("Redis rate limiter available again\n")
+	}
+	return allowed == 1
+}
@@ -1,20 +1,28 @@
 package api
 
 import (
+	"log"
+
 	"llm-inferra/internal/api/handlers"
 	"llm-inferra/internal/api/middleware"
 	"llm-inferra/internal/config"
+	"llm-inferra/internal/database"
+	"llm-inferra/internal/secrets"
 	"llm-inferra/internal/services"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
 type Server struct {
-	db     *gorm.DB
-	config *config.Config
-	router *gin.Engine
+	db            *gorm.DB
+	redis         *redis.Client
+	config        *config.Config
+	router        *gin.Engine
+	prober        *services.ProviderProber
+	invalidations *services.InvalidationBus
 }
 
 func NewServer(db *gorm.DB, cfg *config.Config) *Server {
@@ -23,10 +31,59 @@ func NewServer(db *gorm.DB, cfg *config.Config) *Server {
 		config: cfg,
 	}
 
+	server.setupSecrets()
+	server.setupRedis()
 	server.setupRouter()
 	return server
 }
 
+// setupSecrets installs the master keyring for stored credentials and encrypts any still in plaintext
+func (s *Server) setupSecrets() {
+	keyring, err := secrets.FromConfig(s.config)
+	if err != nil {
+		log.Fatalf("Failed to load encryption master keys: %v", err)
+	}
+	secrets.SetDefault(keyring)
+
+	if _, err := database.ReencryptSecrets(s.db, keyring, false); err != nil {
+		log.Fatalf("Failed to encrypt stored credentials: %v", err)
+	}
+}
+
+// setupRedis connects to Redis when REDIS_URL is set. Without it rate limits and caches are kept
+// per instance.
+func (s *Server) setupRedis() {
+	if s.config.RedisURL == "" {
+		return
+	}
+
+	client, err := database.InitializeRedis(s.config.RedisURL)
+	if client == nil {
+		log.Fatalf("Failed to set up redis: %v", err)
+	}
+	if err != nil {
+		log.Printf("Redis is not reachable yet, falling back to per-instance state until it is: %v", err)
+	}
+	s.redis = client
+}
+
+// rateLimitMiddleware limits requests per RATE_LIMIT_KEY bucket, shared across instances through
+// Redis when it is configured
+func (s *Server) rateLimitMiddleware(llmService *services.LLMService) gin.HandlerFunc {
+	byUser := middleware.KeyByUser(s.config.JWTSecret, llmService.APIKeyOwner)
+	keyFunc, err := middleware.KeyBy(s.config.RateLimitKey, byUser)
+	if err != nil {
+		log.Fatalf("Failed to set up rate limiting: %v", err)
+	}
+
+	if s.redis == nil {
+		limiter := middleware.NewRateLimiter(s.config.RateLimitRPS)
+		limiter.StartCleanup()
+		return middleware.RateLimitWith(limiter, keyFunc)
+	}
+	return middleware.RateLimitWith(middleware.NewRedisRateLimiter(s.redis, s.config.RateLimitRPS), keyFunc)
+}
+
 func (s *Server) setupRouter() {
 	if s.config.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -41,20 +98,31 @@ func (s *Server) setupRouter() {
 	// CORS
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = s.config.CORSOrigins
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "x-api-key", "anthropic-version", "Cache-Control"}
+	corsConfig.ExposeHeaders = []string{"X-Inferra-Provider", "X-Inferra-Model", "X-Inferra-Cache"}
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
 	s.router.Use(cors.New(corsConfig))
 
-	// Rate limiting
-	s.router.Use(middleware.RateLimit(s.config.RateLimitRPS))
-
 	// Initialize services
 	authService := services.NewAuthService(s.db, s.config)
 	userService := services.NewUserService(s.db)
 	providerService := services.NewProviderService(s.db)
 	apiKeyService := services.NewAPIKeyService(s.db)
 	analyticsService := services.NewAnalyticsService(s.db)
-	llmService := services.NewLLMService(s.db, nil, apiKeyService, providerService, analyticsService)
+	llmService := services.NewLLMService(s.db, s.redis, apiKeyService, providerService, analyticsService)
+	analyticsService.SetCircuitBreakers(llmService.CircuitBreakers())
+	apiKeyService.SetCache(llmService.Cache())
+	userService.SetCache(llmService.Cache())
+	providerService.SetCache(llmService.Cache())
+	// Cache and provider invalidations reach the other instances through Redis
+	s.invalidations = services.NewInvalidationBus(s.redis, llmService.Cache(), providerService)
+	llmService.Cache().SetInvalidationBus(s.invalidations)
+	providerService.SetInvalidationBus(s.invalidations)
+	llmService.Budgets().SetReserveEstimates(s.config.ReserveEstimatedCost)
+	s.prober = services.NewProviderProber(s.db, llmService, providerService, s.config.ProbeInterval)
+
+	// Rate limiting, registered before the routes so it covers all of them
+	s.router.Use(s.rateLimitMiddleware(llmService))
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService, userService)
@@ -76,6 +144,8 @@ func (s *Server) setupRouter() {
 	llmAPI := s.router.Group("/v1")
 	{
 		llmAPI.POST("/chat/completions", llmHandler.ChatCompletion)
+		llmAPI.POST("/messages", llmHandler.Messages) // Anthropic Messages API
+		llmAPI.POST("/embeddings", llmHandler.Embeddings)
 		llmAPI.GET("/models", llmHandler.ListModels)
 		llmAPI.GET("/health", llmHandler.HealthCheck)
 	}
@@ -104,6 +174,13 @@ func (s *Server) setupRouter() {
 			providers.DELETE("/:id", providerHandler.DeleteProvider)
 			providers.GET("/:id/models", middleware.PaginationMiddleware(), providerHandler.ListModels)
 			providers.POST("/:id/models", providerHandler.CreateModel)
+
+			// Upstream credentials are shared by every key of the provider, only admins manage them
+			credentials := providers.Group("/:id/credentials", middleware.AdminMiddleware())
+			credentials.GET("", providerHandler.ListCredentials)
+			credentials.POST("", providerHandler.CreateCredential)
+			credentials.PUT("/:credentialId", providerHandler.UpdateCredential)
+			credentials.DELETE("/:credentialId", providerHandler.DeleteCredential)
 		}
 
 		// Model management
@@ -113,6 +190,8 @@ func (s *Server) setupRouter() {
 			models.GET("/:id", providerHandler.GetModel)
 			models.PUT("/:id", providerHandler.UpdateModel)
 			models.DELETE("/:id", providerHandler.DeleteModel)
+			models.GET("/:id/fallbacks", providerHandler.GetModelFallbacks)
+			models.PUT("/:id/fallbacks", providerHandler.SetModelFallbacks)
 		}
 
 		// API Key management
@@ -123,6 +202,7 @@ func (s *Server) setupRouter() {
 			apiKeys.GET("/:id", apiKeyHandler.GetAPIKey)
 			apiKeys.PUT("/:id", apiKeyHandler.UpdateAPIKey)
 			apiKeys.DELETE("/:id", apiKeyHandler.DeleteAPIKey)
+			apiKeys.POST("/:id/revoke", apiKeyHandler.RevokeAPIKey)
 		}
 
 		// Analytics and monitoring
@@ -134,6 +214,7 @@ func (s *Server) setupRouter() {
 			analytics.GET("/users", middleware.PaginationMiddleware(), analyticsHandler.GetUserAnalytics)
 			analytics.GET("/providers", analyticsHandler.GetProviderAnalytics)
 			analytics.GET("/models", analyticsHandler.GetModelAnalytics)
+			analytics.GET("/cache", analyticsHandler.GetCacheAnalytics)
 		}
 
 		// System health (admin only)
@@ -155,5 +236,13 @@ func (s *Server) healthCheck(c *gin.Context) {
 }
 
 func (s *Server) Start(addr string) error {
+	// Background health probing of upstream providers
+	s.prober.Start()
+	defer s.prober.Stop()
+
+	// Invalidations published by other instances
+	s.invalidations.Start()
+	defer s.invalidations.Stop()
+
 	return s.router.Run(addr)
 }
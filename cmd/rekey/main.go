@@ -0,0 +1,45 @@
+// Command rekey re-encrypts stored credentials with the current encryption master key.
+//
+// To rotate the master key, add the new key under a higher version next to the old one
+// (ENCRYPTION_MASTER_KEYS="1:<old>,2:<new>" or the key file), run rekey, then remove the old
+// version once it reports success.
+package main
+
+import (
+	"log"
+
+	"llm-inferra/internal/config"
+	"llm-inferra/internal/database"
+	"llm-inferra/internal/secrets"
+)
+
+func main() {
+	cfg := config.Load()
+
+	keyring, err := secrets.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load encryption master keys: %v", err)
+	}
+	secrets.SetDefault(keyring)
+
+	db, err := database.Initialize(cfg.DatabaseURL, database.DatabasePoolConfig{
+		MaxIdleConns:    cfg.DatabasePool.MaxIdleConns,
+		MaxOpenConns:    cfg.DatabasePool.MaxOpenConns,
+		ConnMaxLifetime: cfg.DatabasePool.ConnMaxLifetime,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	if err := database.Migrate(db); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	stats, err := database.ReencryptSecrets(db, keyring, true)
+	if err != nil {
+		log.Fatalf("Re-encryption stopped: %v", err)
+	}
+
+	log.Printf("Done: updated %d API keys and %d provider credentials, all now use master key version %d",
+		stats.APIKeys, stats.Credentials, keyring.CurrentVersion())
+}
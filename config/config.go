@@ -12,8 +12,25 @@ type Config struct {
 	Environment  string
 	CORSOrigins  []string
 	RateLimitRPS int
+	// What requests are rate limited by: ip, api_key, user or route, comma separated to combine
+	RateLimitKey string
+	// Redis shares rate limits and caches across instances, empty runs without it
+	RedisURL     string
 	TokenExpiry  time.Duration
 	DatabasePool DatabasePoolConfig
+	// Interval between active health probes of upstream providers, 0 disables probing
+	ProbeInterval time.Duration
+	Encryption    EncryptionConfig
+	// Count the estimated cost of in-flight requests against cost limits
+	ReserveEstimatedCost bool
+}
+
+// EncryptionConfig holds the master keys used to encrypt stored credentials. Keys are given as
+// "version:base64key" entries, 32 bytes each, inline and/or in a key file.
+type EncryptionConfig struct {
+	MasterKeys     string
+	KeyFile        string
+	CurrentVersion int // 0 uses the highest configured version
 }
 
 type DatabasePoolConfig struct {
@@ -31,12 +48,21 @@ func Load() *Config {
 			getEnvOrDefault("FRONTEND_URL", "http://localhost:5173"),
 		},
 		RateLimitRPS: getEnvIntOrDefault("RATE_LIMIT_RPS", 100),
+		RateLimitKey: getEnvOrDefault("RATE_LIMIT_KEY", "ip"),
+		RedisURL:     os.Getenv("REDIS_URL"),
 		TokenExpiry:  time.Hour * 24 * 7, // 7 days
 		DatabasePool: DatabasePoolConfig{
 			MaxIdleConns:    getEnvIntOrDefault("DB_MAX_IDLE_CONNS", 10),
 			MaxOpenConns:    getEnvIntOrDefault("DB_MAX_OPEN_CONNS", 100),
 			ConnMaxLifetime: getDurationFromEnvOrDefault("DB_CONN_MAX_LIFETIME", time.Hour),
 		},
+		ProbeInterval: getDurationFromEnvOrDefault("PROVIDER_PROBE_INTERVAL", time.Minute),
+		Encryption: EncryptionConfig{
+			MasterKeys:     os.Getenv("ENCRYPTION_MASTER_KEYS"),
+			KeyFile:        os.Getenv("ENCRYPTION_KEY_FILE"),
+			CurrentVersion: getEnvIntOrDefault("ENCRYPTION_KEY_VERSION", 0),
+		},
+		ReserveEstimatedCost: getEnvBoolOrDefault("BUDGET_RESERVE_ESTIMATES", true),
 	}
 }
 
@@ -56,6 +82,15 @@ func getEnvIntOrDefault(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 func getDurationFromEnvOrDefault(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
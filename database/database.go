@@ -58,6 +58,10 @@ func Migrate(db *gorm.DB) error {
 		&models.RequestLog{},
 		&models.SystemHealth{},
 		&models.LLMRequestLog{},
+		&models.ModelFallback{},
+		&models.LLMRequestAttempt{},
+		&models.ProviderProbe{},
+		&models.ProviderCredential{},
 	)
 
 	if err != nil {
@@ -179,6 +183,12 @@ func seedDefaultModels(db *gorm.DB) error {
 		return fmt.Errorf("failed to find Anthropic provider: %w", err)
 	}
 
+	// Get Google provider
+	var googleProvider models.Provider
+	if err := db.Where("name = ?", "Google").First(&googleProvider).Error; err != nil {
+		return fmt.Errorf("failed to find Google provider: %w", err)
+	}
+
 	defaultModels := []models.LLMModel{
 		{
 			ProviderID:        openaiProvider.ID,
@@ -203,6 +213,24 @@ func seedDefaultModels(db *gorm.DB) error {
 			SupportsStreaming: true,
 			SupportsFunctions: true,
 		},
+		{
+			ProviderID:         openaiProvider.ID,
+			Name:               "Text Embedding 3 Small",
+			ModelID:            "text-embedding-3-small",
+			Description:        "Efficient OpenAI embedding model",
+			MaxTokens:          8191,
+			InputCostPer1K:     0.00002,
+			SupportsEmbeddings: true,
+		},
+		{
+			ProviderID:         openaiProvider.ID,
+			Name:               "Text Embedding 3 Large",
+			ModelID:            "text-embedding-3-large",
+			Description:        "Most capable OpenAI embedding model",
+			MaxTokens:          8191,
+			InputCostPer1K:     0.00013,
+			SupportsEmbeddings: true,
+		},
 		{
 			ProviderID:        anthropicProvider.ID,
 			Name:              "Claude 3 Sonnet",
@@ -212,6 +240,8 @@ func seedDefaultModels(db *gorm.DB) error {
 			InputCostPer1K:    0.003,
 			OutputCostPer1K:   0.015,
 			SupportsStreaming: true,
+			SupportsFunctions: true,
+			SupportsVision:    true,
 		},
 		{
 			ProviderID:        anthropicProvider.ID,
@@ -222,6 +252,41 @@ func seedDefaultModels(db *gorm.DB) error {
 			InputCostPer1K:    0.00025,
 			OutputCostPer1K:   0.00125,
 			SupportsStreaming: true,
+			SupportsFunctions: true,
+			SupportsVision:    true,
+		},
+		{
+			ProviderID:        googleProvider.ID,
+			Name:              "Gemini 1.5 Pro",
+			ModelID:           "gemini-1.5-pro",
+			Description:       "Most capable Gemini model with long context",
+			MaxTokens:         8192,
+			InputCostPer1K:    0.00125,
+			OutputCostPer1K:   0.005,
+			SupportsStreaming: true,
+			SupportsFunctions: true,
+			SupportsVision:    true,
+		},
+		{
+			ProviderID:        googleProvider.ID,
+			Name:              "Gemini 1.5 Flash",
+			ModelID:           "gemini-1.5-flash",
+			Description:       "Fast and efficient Gemini model",
+			MaxTokens:         8192,
+			InputCostPer1K:    0.000075,
+			OutputCostPer1K:   0.0003,
+			SupportsStreaming: true,
+			SupportsFunctions: true,
+			SupportsVision:    true,
+		},
+		{
+			ProviderID:         googleProvider.ID,
+			Name:               "Text Embedding 004",
+			ModelID:            "text-embedding-004",
+			Description:        "Gemini API text embedding model",
+			MaxTokens:          2048,
+			InputCostPer1K:     0.00001,
+			SupportsEmbeddings: true,
 		},
 	}
 
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// InitializeRedis connects to Redis at redisURL (redis://[:password@]host:port/db). The client is
+// returned even when the first ping fails, callers fall back to local state until Redis is back.
+func InitializeRedis(redisURL string) (*redis.Client, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return client, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	log.Println("Redis connection established")
+	return client, nil
+}
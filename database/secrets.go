@@ -0,0 +1,120 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"llm-inferra/internal/secrets"
+
+	"gorm.io/gorm"
+)
+
+const reencryptBatchSize = 500
+
+// ReencryptStats counts the rows rewritten by ReencryptSecrets
+type ReencryptStats struct {
+	APIKeys     int
+	Credentials int
+}
+
+// ReencryptSecrets encrypts credentials still stored in plaintext, backfills API key digests and
+// display prefixes, and drops the stored value of virtual keys, which only need their digest.
+// With rotate set it also re-wraps values encrypted under an older master key version with the
+// current one, after which the old version can be removed from the keyring.
+// Soft-deleted rows are included, they would otherwise still leak from a database dump.
+func ReencryptSecrets(db *gorm.DB, keyring *secrets.Keyring, rotate bool) (*ReencryptStats, error) {
+	stats := &ReencryptStats{}
+
+	apiKeys, err := reencryptTable(db, keyring, "api_keys", true, rotate)
+	if err != nil {
+		return stats, err
+	}
+	stats.APIKeys = apiKeys
+
+	credentials, err := reencryptTable(db, keyring, "provider_credentials", false, rotate)
+	if err != nil {
+		return stats, err
+	}
+	stats.Credentials = credentials
+
+	if stats.APIKeys > 0 || stats.Credentials > 0 {
+		log.Printf("Updated stored secrets of %d API keys and %d provider credentials (master key version %d)",
+			stats.APIKeys, stats.Credentials, keyring.CurrentVersion())
+	}
+	return stats, nil
+}
+
+// reencryptTable works through a table's key_value column in id order. The table is read without
+// the model so values are seen exactly as stored. isAPIKeys enables the client key columns.
+func reencryptTable(db *gorm.DB, keyring *secrets.Keyring, table string, isAPIKeys, rotate bool) (int, error) {
+	type row struct {
+		ID        uint
+		KeyValue  string
+		KeyHash   string
+		KeyPrefix string
+		Virtual   bool
+	}
+
+	columns := "id, key_value"
+	if isAPIKeys {
+		columns += ", key_hash, key_prefix, virtual"
+	}
+
+	updated := 0
+	var lastID uint
+	for {
+		var rows []row
+		err := db.Table(table).Select(columns).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(reencryptBatchSize).
+			Scan(&rows).Error
+		if err != nil {
+			return updated, fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		if len(rows) == 0 {
+			return updated, nil
+		}
+
+		for _, r := range rows {
+			lastID = r.ID
+			updates := map[string]interface{}{}
+
+			if isAPIKeys && r.KeyValue != "" && (r.KeyHash == "" || r.KeyPrefix == "" || r.Virtual) {
+				plaintext, err := keyring.Decrypt(r.KeyValue)
+				if err != nil {
+					return updated, fmt.Errorf("failed to decrypt %s %d: %w", table, r.ID, err)
+				}
+				if r.KeyHash == "" {
+					updates["key_hash"] = secrets.Digest(plaintext)
+				}
+				if r.KeyPrefix == "" {
+					updates["key_prefix"] = secrets.DisplayPrefix(plaintext)
+				}
+			}
+
+			switch {
+			case isAPIKeys && r.Virtual:
+				if r.KeyValue != "" {
+					updates["key_value"] = ""
+				}
+			case r.KeyValue != "" && (!secrets.IsEncrypted(r.KeyValue) || rotate):
+				rewrapped, changed, err := keyring.Rewrap(r.KeyValue)
+				if err != nil {
+					return updated, fmt.Errorf("failed to re-encrypt %s %d: %w", table, r.ID, err)
+				}
+				if changed {
+					updates["key_value"] = rewrapped
+				}
+			}
+
+			if len(updates) == 0 {
+				continue
+			}
+			if err := db.Table(table).Where("id = ?", r.ID).Updates(updates).Error; err != nil {
+				return updated, fmt.Errorf("failed to update %s %d: %w", table, r.ID, err)
+			}
+			updated++
+		}
+	}
+}
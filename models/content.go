@@ -0,0 +1,106 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MessageContent is the content of a chat message. Like the OpenAI API it accepts either
+// a plain string or an array of typed parts (text, image_url, base64 image).
+type MessageContent struct {
+	Text  string
+	Parts []ContentPart
+}
+
+type ContentPart struct {
+	Type     string       `json:"type"` // "text", "image_url" or "image"
+	Text     string       `json:"text,omitempty"`
+	ImageURL *ImageURL    `json:"image_url,omitempty"`
+	Source   *ImageSource `json:"source,omitempty"` // base64 image, Anthropic style
+}
+
+type ImageURL struct {
+	URL    string `json:"url"` // http(s) URL or data:<media type>;base64,<data>
+	Detail string `json:"detail,omitempty"`
+}
+
+type ImageSource struct {
+	Type      string `json:"type"` // "base64"
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// Content part types
+const (
+	ContentPartText     = "text"
+	ContentPartImageURL = "image_url"
+	ContentPartImage    = "image"
+)
+
+// TextContent creates plain string content
+func TextContent(text string) MessageContent {
+	return MessageContent{Text: text}
+}
+
+// String returns the text of the content, concatenating text parts
+func (c MessageContent) String() string {
+	if c.Parts == nil {
+		return c.Text
+	}
+
+	var sb strings.Builder
+	for _, part := range c.Parts {
+		if part.Type == ContentPartText {
+			sb.WriteString(part.Text)
+		}
+	}
+	return sb.String()
+}
+
+// IsEmpty reports whether the content has no text and no parts
+func (c MessageContent) IsEmpty() bool {
+	return c.Text == "" && len(c.Parts) == 0
+}
+
+// HasImages reports whether the content contains any image part
+func (c MessageContent) HasImages() bool {
+	for _, part := range c.Parts {
+		if part.Type == ContentPartImageURL || part.Type == ContentPartImage {
+			return true
+		}
+	}
+	return false
+}
+
+func (c MessageContent) MarshalJSON() ([]byte, error) {
+	if c.Parts == nil {
+		return json.Marshal(c.Text)
+	}
+	return json.Marshal(c.Parts)
+}
+
+func (c *MessageContent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	*c = MessageContent{}
+
+	switch {
+	case len(data) == 0 || bytes.Equal(data, []byte("null")):
+		return nil
+	case data[0] == '"':
+		return json.Unmarshal(data, &c.Text)
+	case data[0] == '[':
+		var parts []ContentPart
+		if err := json.Unmarshal(data, &parts); err != nil {
+			return err
+		}
+		if parts == nil {
+			parts = []ContentPart{}
+		}
+		c.Parts = parts
+		return nil
+	default:
+		return fmt.Errorf("content must be a string or an array of content parts")
+	}
+}
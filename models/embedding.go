@@ -0,0 +1,69 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Embedding request/response models (OpenAI format)
+type EmbeddingRequest struct {
+	Model          string         `json:"model" validate:"required"`
+	Input          EmbeddingInput `json:"input" validate:"required"` // string or array of strings
+	Dimensions     *int           `json:"dimensions,omitempty"`      // output dimensionality, if the model supports it
+	EncodingFormat string         `json:"encoding_format,omitempty"` // "float" (default) or "base64"
+	User           string         `json:"user,omitempty"`
+}
+
+// EmbeddingInput accepts a single string or an array of strings
+type EmbeddingInput []string
+
+// Embedding encoding formats
+const (
+	EmbeddingEncodingFloat  = "float"
+	EmbeddingEncodingBase64 = "base64"
+)
+
+type EmbeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  EmbeddingUsage  `json:"usage"`
+}
+
+type EmbeddingData struct {
+	Object    string      `json:"object"`
+	Index     int         `json:"index"`
+	Embedding interface{} `json:"embedding"` // []float64, or a base64 string of little-endian float32s
+}
+
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+func (in *EmbeddingInput) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	*in = nil
+
+	switch {
+	case len(data) == 0 || bytes.Equal(data, []byte("null")):
+		return nil
+	case data[0] == '"':
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*in = EmbeddingInput{text}
+		return nil
+	case data[0] == '[':
+		var texts []string
+		if err := json.Unmarshal(data, &texts); err != nil {
+			return fmt.Errorf("input must be a string or an array of strings")
+		}
+		*in = texts
+		return nil
+	default:
+		return fmt.Errorf("input must be a string or an array of strings")
+	}
+}
@@ -16,13 +16,62 @@ type ChatCompletionRequest struct {
 	Stop        interface{}            `json:"stop,omitempty"`
 	System      string                 `json:"system,omitempty"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	// Streaming options
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"` // include_usage appends a final usage chunk
+	StreamFormat  string         `json:"stream_format,omitempty"`  // "openai" (default) or "anthropic" for native passthrough
+	// Tool / function calling
+	Tools      []Tool      `json:"tools,omitempty"`
+	ToolChoice interface{} `json:"tool_choice,omitempty"` // "auto", "none", "required" or {"type":"function","function":{"name":...}}
+	// Deprecated OpenAI function calling fields, normalized into Tools/ToolChoice
+	Functions    []FunctionDefinition `json:"functions,omitempty"`
+	FunctionCall interface{}          `json:"function_call,omitempty"`
+	// Only honored by Anthropic and Gemini
+	TopK *int `json:"top_k,omitempty"`
 	// Anthropic specific fields
 	AnthropicVersion string `json:"anthropic_version,omitempty"`
 }
 
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// Stream formats
+const (
+	StreamFormatOpenAI    = "openai"
+	StreamFormatAnthropic = "anthropic"
+)
+
 type ChatMessage struct {
-	Role    string `json:"role" validate:"required"`
-	Content string `json:"content" validate:"required"`
+	Role    string         `json:"role" validate:"required"`
+	Content MessageContent `json:"content"` // string or array of content parts
+	// Tool calls requested by the assistant
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// Set on "tool" role messages carrying a tool result
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+type Tool struct {
+	Type     string             `json:"type"` // only "function" is supported
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionDefinition struct {
+	Name        string          `json:"name" validate:"required"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"` // JSON Schema
+}
+
+type ToolCall struct {
+	Index    *int             `json:"index,omitempty"` // only set in streaming deltas
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON encoded arguments
 }
 
 type ChatCompletionResponse struct {
@@ -39,8 +88,11 @@ type ChatCompletionResponse struct {
 }
 
 type ChatCompletionContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
 }
 
 type ChatCompletionChoice struct {
@@ -71,8 +123,11 @@ type AnthropicResponse struct {
 }
 
 type AnthropicContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type  string          `json:"type"`
+	Text  string          `json:"text,omitempty"`
+	ID    string          `json:"id,omitempty"`    // tool_use
+	Name  string          `json:"name,omitempty"`  // tool_use
+	Input json.RawMessage `json:"input,omitempty"` // tool_use
 }
 
 type AnthropicUsage struct {
@@ -80,6 +135,75 @@ type AnthropicUsage struct {
 	OutputTokens int `json:"output_tokens"`
 }
 
+// OpenAI specific response structures
+type OpenAIResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   OpenAIUsage    `json:"usage"`
+}
+
+type OpenAIChoice struct {
+	Index        int         `json:"index"`
+	Message      ChatMessage `json:"message"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Google Gemini specific response structures
+type GeminiResponse struct {
+	ResponseID    string              `json:"responseId,omitempty"`
+	ModelVersion  string              `json:"modelVersion,omitempty"`
+	Candidates    []GeminiCandidate   `json:"candidates"`
+	UsageMetadata GeminiUsageMetadata `json:"usageMetadata"`
+}
+
+type GeminiCandidate struct {
+	Index        int           `json:"index"`
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64
+}
+
+type GeminiFunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type GeminiFunctionResponse struct {
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
 // LLM Request Log for tracking
 type LLMRequestLog struct {
 	ID        uint       `json:"id" gorm:"primaryKey"`
@@ -119,9 +243,38 @@ type LLMRequestLog struct {
 	ErrorMessage string `json:"error_message"`
 	HTTPStatus   int    `json:"http_status" gorm:"default:0"`
 
+	// Response cache, SavedCost is the cost of the original call a cache hit replayed
+	CacheHit    bool    `json:"cache_hit" gorm:"default:false"`
+	CacheStatus string  `json:"cache_status,omitempty" gorm:"index"` // hit, semantic-hit, miss or bypass, empty when not cacheable
+	SavedCost   float64 `json:"saved_cost" gorm:"default:0"`
+
 	// Client info
 	ClientIP  string `json:"client_ip"`
 	UserAgent string `json:"user_agent"`
+
+	// Upstream attempts, more than one when a fallback chain was used
+	Attempts []LLMRequestAttempt `json:"attempts,omitempty" gorm:"foreignKey:RequestLogID"`
+
+	// Pooled upstream credential that served the request, if any
+	CredentialID *uint `json:"credential_id,omitempty" gorm:"index"`
+}
+
+// LLMRequestAttempt records a single upstream call made for a request
+type LLMRequestAttempt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RequestLogID uint   `json:"request_log_id" gorm:"not null;index"`
+	Attempt      int    `json:"attempt" gorm:"not null"` // 1 is the primary model
+	ProviderID   uint   `json:"provider_id" gorm:"not null"`
+	ModelID      uint   `json:"model_id" gorm:"not null"`
+	ModelName    string `json:"model_name" gorm:"not null"`
+	CredentialID *uint  `json:"credential_id,omitempty" gorm:"index"`
+
+	Status       string `json:"status"` // completed, failed, skipped
+	ErrorMessage string `json:"error_message"`
+	HTTPStatus   int    `json:"http_status" gorm:"default:0"`
+	LatencyMs    int64  `json:"latency_ms" gorm:"default:0"`
 }
 
 // Request processing metadata
@@ -135,6 +288,34 @@ type LLMRequestContext struct {
 	ClientIP  string
 	UserAgent string
 	StartTime time.Time
+
+	// Done is closed once the client has gone away, streams stop forwarding to it then. Set by the
+	// handlers of streaming requests.
+	Done <-chan struct{}
+
+	// Upstream credential picked from the provider's pool; when unset APIKey.KeyValue is sent upstream
+	CredentialID *uint
+	UpstreamKey  string
+
+	// Requests and tokens per minute left after admission, nil when no rate limit applies
+	RateLimit *RateLimitStatus
+
+	// NoResponseCache skips the response cache lookup (Cache-Control: no-cache), the response is
+	// still stored. ResponseCacheStatus is "hit", "semantic-hit", "miss" or "bypass", empty when
+	// caching is off.
+	NoResponseCache     bool
+	ResponseCacheStatus string
+}
+
+// RateLimitStatus is the tightest requests per minute and tokens per minute limit of a request,
+// returned as x-ratelimit-* headers. A zero limit means none applies.
+type RateLimitStatus struct {
+	LimitRequests     int
+	RemainingRequests int
+	ResetRequests     time.Duration
+	LimitTokens       int
+	RemainingTokens   int
+	ResetTokens       time.Duration
 }
 
 // Provider adapter interface
@@ -146,3 +327,8 @@ type LLMProvider interface {
 	TransformResponse(resp interface{}) (*ChatCompletionResponse, error)
 	CalculateCost(usage *ChatCompletionUsage, model *LLMModel) (inputCost, outputCost, totalCost float64)
 }
+
+// EmbeddingProvider is implemented by provider adapters that can create embeddings
+type EmbeddingProvider interface {
+	Embeddings(ctx *LLMRequestContext, req *EmbeddingRequest) (*EmbeddingResponse, error)
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Native Anthropic Messages API request (POST /v1/messages). Responses reuse AnthropicResponse.
+type MessagesRequest struct {
+	Model         string                 `json:"model" validate:"required"`
+	Messages      []MessagesMessage      `json:"messages" validate:"required"`
+	System        MessagesContent        `json:"system,omitempty"` // string or array of text blocks
+	MaxTokens     int                    `json:"max_tokens" validate:"required"`
+	Temperature   *float64               `json:"temperature,omitempty"`
+	TopP          *float64               `json:"top_p,omitempty"`
+	TopK          *int                   `json:"top_k,omitempty"`
+	StopSequences []string               `json:"stop_sequences,omitempty"`
+	Stream        bool                   `json:"stream,omitempty"`
+	Tools         []MessagesTool         `json:"tools,omitempty"`
+	ToolChoice    *MessagesToolChoice    `json:"tool_choice,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type MessagesMessage struct {
+	Role    string          `json:"role" validate:"required"` // "user" or "assistant"
+	Content MessagesContent `json:"content"`
+}
+
+// MessagesContent accepts either a plain string or an array of content blocks
+type MessagesContent struct {
+	Text   string
+	Blocks []MessagesContentBlock
+}
+
+type MessagesContentBlock struct {
+	Type string `json:"type"` // text, image, tool_use, tool_result
+	Text string `json:"text,omitempty"`
+	// image
+	Source *MessagesImageSource `json:"source,omitempty"`
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// tool_result
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   *MessagesContent `json:"content,omitempty"`
+	IsError   bool             `json:"is_error,omitempty"`
+}
+
+type MessagesImageSource struct {
+	Type      string `json:"type"` // "base64" or "url"
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type MessagesTool struct {
+	Name        string          `json:"name" validate:"required"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type MessagesToolChoice struct {
+	Type string `json:"type"` // auto, any, tool, none
+	Name string `json:"name,omitempty"`
+}
+
+func (c MessagesContent) MarshalJSON() ([]byte, error) {
+	if c.Blocks == nil {
+		return json.Marshal(c.Text)
+	}
+	return json.Marshal(c.Blocks)
+}
+
+func (c *MessagesContent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	*c = MessagesContent{}
+
+	switch {
+	case len(data) == 0 || bytes.Equal(data, []byte("null")):
+		return nil
+	case data[0] == '"':
+		return json.Unmarshal(data, &c.Text)
+	case data[0] == '[':
+		var blocks []MessagesContentBlock
+		if err := json.Unmarshal(data, &blocks); err != nil {
+			return err
+		}
+		if blocks == nil {
+			blocks = []MessagesContentBlock{}
+		}
+		c.Blocks = blocks
+		return nil
+	default:
+		return fmt.Errorf("content must be a string or an array of content blocks")
+	}
+}
@@ -3,6 +3,9 @@ package models
 import (
 	"time"
 
+	// Registers the "encrypted" serializer used for stored credentials
+	_ "llm-inferra/internal/secrets"
+
 	"gorm.io/gorm"
 )
 
@@ -17,19 +20,82 @@ type Provider struct {
 	Status      ProviderStatus `json:"status" gorm:"default:active"`
 	Description string         `json:"description"`
 
+	// Who set the current status: empty for an admin, ProviderStatusReasonProbe for the health prober
+	StatusReason string `json:"status_reason" gorm:"size:20;not null;default:''"`
+
 	// Configuration
 	BaseURL    string `json:"base_url"`
 	APIVersion string `json:"api_version"`
 
 	// Rate limiting and costs
-	DefaultRateLimit    int     `json:"default_rate_limit" gorm:"default:60"`        // requests per minute
+	DefaultRateLimit    int     `json:"default_rate_limit" gorm:"default:60"`        // requests per minute of each key without its own limit
+	TokensPerMinute     int     `json:"tokens_per_minute" gorm:"default:0"`          // 0 is unlimited
 	DefaultCostPerToken float64 `json:"default_cost_per_token" gorm:"default:0.001"` // per 1K tokens
 
+	// Retry policy for upstream calls (rate limits, overload and gateway errors, connection failures)
+	MaxRetryAttempts   int `json:"max_retry_attempts" gorm:"default:3"`     // total attempts including the first, 1 disables retries
+	RetryBaseDelayMs   int `json:"retry_base_delay_ms" gorm:"default:500"`  // exponential backoff base, with jitter
+	RetryMaxDelayMs    int `json:"retry_max_delay_ms" gorm:"default:10000"` // longer Retry-After values are not waited for
+	RetryBudgetPercent int `json:"retry_budget_percent" gorm:"default:20"`  // retries allowed as a share of requests
+
+	// Circuit breaker, applied to the provider and to each of its models
+	BreakerConsecutiveFailures int `json:"breaker_consecutive_failures" gorm:"default:5"` // open after this many failures in a row
+	BreakerErrorRatePercent    int `json:"breaker_error_rate_percent" gorm:"default:50"`  // or when the error rate over the last minute exceeds this
+	BreakerMinRequests         int `json:"breaker_min_requests" gorm:"default:20"`        // requests needed before the error rate is considered
+	BreakerOpenSeconds         int `json:"breaker_open_seconds" gorm:"default:30"`        // time open before a half-open probe
+
+	// How requests are spread over the provider's upstream credential pool
+	CredentialStrategy CredentialStrategy `json:"credential_strategy" gorm:"default:weighted_round_robin"`
+
+	// Active health probing, done with ProbeAPIKeyID's credentials against the cheapest model
+	ProbeAPIKeyID         *uint `json:"probe_api_key_id"`
+	ProbeFailureThreshold int   `json:"probe_failure_threshold" gorm:"default:3"` // failed probes in a row before maintenance
+
 	// Relationships
-	Models  []LLMModel `json:"models,omitempty" gorm:"foreignKey:ProviderID"`
-	APIKeys []APIKey   `json:"api_keys,omitempty" gorm:"foreignKey:ProviderID"`
+	Models      []LLMModel           `json:"models,omitempty" gorm:"foreignKey:ProviderID"`
+	APIKeys     []APIKey             `json:"api_keys,omitempty" gorm:"foreignKey:ProviderID"`
+	Credentials []ProviderCredential `json:"credentials,omitempty" gorm:"foreignKey:ProviderID"`
+}
+
+type CredentialStrategy string
+
+const (
+	CredentialWeightedRoundRobin CredentialStrategy = "weighted_round_robin"
+	CredentialLeastInFlight      CredentialStrategy = "least_in_flight"
+	CredentialSkipRateLimited    CredentialStrategy = "skip_rate_limited" // weighted round-robin over keys not currently returning 429
+)
+
+// ProviderCredential is an upstream API key in a provider's credential pool. When a provider has
+// active credentials, requests are sent upstream with one of them instead of the client's key.
+type ProviderCredential struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	ProviderID uint             `json:"provider_id" gorm:"not null;index"`
+	Name       string           `json:"name" gorm:"not null" validate:"required"`
+	KeyValue   string           `json:"-" gorm:"not null;serializer:encrypted"`
+	Weight     int              `json:"weight" gorm:"default:1"`
+	Status     CredentialStatus `json:"status" gorm:"default:active"`
+
+	// Usage tracking
+	TotalRequests       int64      `json:"total_requests" gorm:"default:0"`
+	FailedRequests      int64      `json:"failed_requests" gorm:"default:0"`
+	RateLimitedRequests int64      `json:"rate_limited_requests" gorm:"default:0"`
+	TotalTokens         int64      `json:"total_tokens" gorm:"default:0"`
+	TotalCost           float64    `json:"total_cost" gorm:"default:0"`
+	LastUsedAt          *time.Time `json:"last_used_at"`
+	LastRateLimitedAt   *time.Time `json:"last_rate_limited_at"`
 }
 
+type CredentialStatus string
+
+const (
+	CredentialStatusActive   CredentialStatus = "active"
+	CredentialStatusInactive CredentialStatus = "inactive"
+)
+
 type ProviderType string
 
 const (
@@ -47,6 +113,10 @@ const (
 	ProviderStatusMaintenance ProviderStatus = "maintenance"
 )
 
+// ProviderStatusReasonProbe marks a provider the health prober moved to maintenance, it is moved
+// back to active once probes pass again
+const ProviderStatusReasonProbe = "probe"
+
 type LLMModel struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -72,12 +142,31 @@ type LLMModel struct {
 	SupportsVision     bool `json:"supports_vision" gorm:"default:false"`
 	SupportsEmbeddings bool `json:"supports_embeddings" gorm:"default:false"`
 
+	// Rate limits across all keys, 0 is unlimited
+	RequestsPerMinute int `json:"requests_per_minute" gorm:"default:0"`
+	TokensPerMinute   int `json:"tokens_per_minute" gorm:"default:0"`
+
 	// Usage tracking
 	TotalRequests int64   `json:"total_requests" gorm:"default:0"`
 	TotalCost     float64 `json:"total_cost" gorm:"default:0"`
 
 	// Relationships
-	UsageLogs []UsageLog `json:"usage_logs,omitempty" gorm:"foreignKey:ModelID"`
+	UsageLogs []UsageLog      `json:"usage_logs,omitempty" gorm:"foreignKey:ModelID"`
+	Fallbacks []ModelFallback `json:"fallbacks,omitempty" gorm:"foreignKey:ModelID"`
+}
+
+// ModelFallback is one entry of a model's fallback chain. When the primary model fails with a
+// retryable error (5xx, 429, timeout, connection error) the fallbacks are tried in Priority order.
+type ModelFallback struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	ModelID         uint     `json:"model_id" gorm:"not null;index"`
+	FallbackModelID uint     `json:"fallback_model_id" gorm:"not null"`
+	FallbackModel   LLMModel `json:"fallback_model,omitempty"`
+	Priority        int      `json:"priority" gorm:"default:0"` // lower runs first
 }
 
 type ModelStatus string
@@ -100,7 +189,8 @@ type APIKey struct {
 	Provider   Provider `json:"provider,omitempty"`
 
 	Name      string       `json:"name" gorm:"not null" validate:"required"`
-	KeyValue  string       `json:"-" gorm:"not null"` // Encrypted API key
+	KeyValue  string       `json:"-" gorm:"not null;serializer:encrypted"` // Encrypted upstream key of pass-through keys, empty for virtual keys
+	KeyHash   string       `json:"-" gorm:"index"`                         // SHA-256 of the key, for lookups
 	Status    APIKeyStatus `json:"status" gorm:"default:active"`
 	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
 
@@ -109,6 +199,27 @@ type APIKey struct {
 	MonthlyRequestLimit int64   `json:"monthly_request_limit" gorm:"default:10000"`
 	DailyCostLimit      float64 `json:"daily_cost_limit" gorm:"default:10.0"`
 	MonthlyCostLimit    float64 `json:"monthly_cost_limit" gorm:"default:100.0"`
+	RequestsPerMinute   int     `json:"requests_per_minute" gorm:"default:0"` // 0 uses the provider's DefaultRateLimit
+	TokensPerMinute     int     `json:"tokens_per_minute" gorm:"default:0"`
+
+	// Seconds to keep responses of deterministic (temperature 0) requests for replay, 0 disables
+	// the response cache
+	ResponseCacheTTL int `json:"response_cache_ttl" gorm:"default:0"`
+	// Semantic cache on top of it: a cached response is also returned for requests whose final user
+	// message embeds, with SemanticCacheModel, within SemanticCacheThreshold cosine similarity of a
+	// cached one. A threshold of 0 disables it.
+	SemanticCacheThreshold float64 `json:"semantic_cache_threshold" gorm:"default:0"`
+	SemanticCacheModel     string  `json:"semantic_cache_model"` // embedding model of the key's provider
+
+	// Virtual keys are minted by the gateway (sk-inferra-...) and are never sent upstream. Calls are
+	// made with UpstreamCredentialID, or with the provider's credential pool when it is unset.
+	Virtual              bool   `json:"virtual" gorm:"default:false"`
+	KeyPrefix            string `json:"key_prefix"` // start of the key, for display
+	UpstreamCredentialID *uint  `json:"upstream_credential_id"`
+
+	// Scopes, empty allows everything
+	AllowedModels []string `json:"allowed_models" gorm:"serializer:json"`
+	Scopes        []string `json:"scopes" gorm:"serializer:json"` // see APIKeyScope*
 
 	// Usage tracking
 	TotalRequests   int64      `json:"total_requests" gorm:"default:0"`
@@ -131,12 +242,31 @@ const (
 	APIKeyStatusRevoked  APIKeyStatus = "revoked"
 )
 
+// VirtualKeyPrefix starts every gateway-issued key
+const VirtualKeyPrefix = "sk-inferra-"
+
+// API key scopes
+const (
+	APIKeyScopeChat       = "chat" // chat completions and messages
+	APIKeyScopeEmbeddings = "embeddings"
+)
+
 type CreateProviderRequest struct {
 	Name        string       `json:"name" validate:"required"`
 	Type        ProviderType `json:"type" validate:"required"`
 	Description string       `json:"description"`
 	BaseURL     string       `json:"base_url"`
 	APIVersion  string       `json:"api_version"`
+	// Retry policy, zero values use the defaults
+	MaxRetryAttempts   int `json:"max_retry_attempts"`
+	RetryBaseDelayMs   int `json:"retry_base_delay_ms"`
+	RetryMaxDelayMs    int `json:"retry_max_delay_ms"`
+	RetryBudgetPercent int `json:"retry_budget_percent"`
+	// How requests are spread over the provider's credential pool
+	CredentialStrategy CredentialStrategy `json:"credential_strategy"`
+	// Rate limits, a zero requests per minute uses the default
+	DefaultRateLimit int `json:"default_rate_limit"`
+	TokensPerMinute  int `json:"tokens_per_minute"`
 }
 
 type CreateModelRequest struct {
@@ -151,14 +281,50 @@ type CreateModelRequest struct {
 	SupportsFunctions  bool    `json:"supports_functions"`
 	SupportsVision     bool    `json:"supports_vision"`
 	SupportsEmbeddings bool    `json:"supports_embeddings"`
+	RequestsPerMinute  int     `json:"requests_per_minute"`
+	TokensPerMinute    int     `json:"tokens_per_minute"`
+}
+
+type CreateCredentialRequest struct {
+	Name     string `json:"name" validate:"required"`
+	KeyValue string `json:"key_value" validate:"required"`
+	Weight   int    `json:"weight"`
+}
+
+type UpdateCredentialRequest struct {
+	Name     *string           `json:"name"`
+	KeyValue *string           `json:"key_value"`
+	Weight   *int              `json:"weight"`
+	Status   *CredentialStatus `json:"status"`
+}
+
+type SetModelFallbacksRequest struct {
+	FallbackModelIDs []uint `json:"fallback_model_ids"` // in the order they should be tried
 }
 
 type CreateAPIKeyRequest struct {
 	ProviderID          uint    `json:"provider_id" validate:"required"`
 	Name                string  `json:"name" validate:"required"`
-	KeyValue            string  `json:"key_value" validate:"required"`
+	KeyValue            string  `json:"key_value"` // legacy pass-through key, leave empty to mint a virtual key
 	DailyRequestLimit   int64   `json:"daily_request_limit"`
 	MonthlyRequestLimit int64   `json:"monthly_request_limit"`
 	DailyCostLimit      float64 `json:"daily_cost_limit"`
 	MonthlyCostLimit    float64 `json:"monthly_cost_limit"`
+	RequestsPerMinute   int     `json:"requests_per_minute"`
+	TokensPerMinute     int     `json:"tokens_per_minute"`
+	ResponseCacheTTL    int     `json:"response_cache_ttl"` // seconds, 0 disables the response cache
+	// Semantic cache, see APIKey
+	SemanticCacheThreshold float64 `json:"semantic_cache_threshold"`
+	SemanticCacheModel     string  `json:"semantic_cache_model"`
+	// Virtual key options
+	UpstreamCredentialID *uint      `json:"upstream_credential_id"`
+	AllowedModels        []string   `json:"allowed_models"`
+	Scopes               []string   `json:"scopes"`
+	ExpiresAt            *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse carries the minted virtual key, which is only ever shown once
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key,omitempty"`
 }
@@ -87,6 +87,12 @@ type ProviderMetric struct {
 	Requests     int64   `json:"requests"`
 	Cost         float64 `json:"cost"`
 	SuccessRate  float64 `json:"success_rate"`
+
+	// Active probing over the last 24 hours
+	Uptime          *float64   `json:"uptime,omitempty"` // percentage of successful probes
+	ProbeLatencyMs  float64    `json:"probe_latency_ms,omitempty"`
+	LastProbeAt     *time.Time `json:"last_probe_at,omitempty"`
+	LastProbeStatus string     `json:"last_probe_status,omitempty"` // "up" or "down"
 }
 
 type ModelMetric struct {
@@ -105,6 +111,30 @@ type UserMetric struct {
 	LastRequest time.Time `json:"last_request"`
 }
 
+// CacheAnalytics reports how often the response cache served requests. Hit rates are hits over
+// lookups, requests that bypassed the cache with Cache-Control: no-cache are not counted.
+type CacheAnalytics struct {
+	Since           time.Time          `json:"since"`
+	Lookups         int64              `json:"lookups"`
+	Hits            int64              `json:"hits"`
+	ExactHits       int64              `json:"exact_hits"`
+	SemanticHits    int64              `json:"semantic_hits"`
+	Misses          int64              `json:"misses"`
+	Bypassed        int64              `json:"bypassed"`
+	HitRate         float64            `json:"hit_rate"`
+	SemanticHitRate float64            `json:"semantic_hit_rate"`
+	SavedCost       float64            `json:"saved_cost"`
+	Daily           []DailyCacheMetric `json:"daily,omitempty"`
+}
+
+type DailyCacheMetric struct {
+	Date      string  `json:"date"`
+	Lookups   int64   `json:"lookups"`
+	Hits      int64   `json:"hits"`
+	HitRate   float64 `json:"hit_rate"`
+	SavedCost float64 `json:"saved_cost"`
+}
+
 // Request/Response logging
 type RequestLog struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
@@ -144,4 +174,42 @@ type SystemHealth struct {
 	// Provider status
 	ProvidersOnline  int `json:"providers_online"`
 	ProvidersOffline int `json:"providers_offline"`
+
+	// Circuit breaker state per provider and model, not persisted
+	CircuitBreakers []CircuitBreakerStatus `json:"circuit_breakers" gorm:"-"`
+}
+
+// ProviderProbe is the result of one active health probe of a provider
+type ProviderProbe struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	ProviderID   uint   `json:"provider_id" gorm:"not null;index"`
+	ModelName    string `json:"model_name"`
+	Success      bool   `json:"success"`
+	LatencyMs    int64  `json:"latency_ms"`
+	HTTPStatus   int    `json:"http_status"`
+	ErrorMessage string `json:"error_message"`
+	// The failure points at the provider (5xx, timeout, connection error) rather than at the
+	// probe key or request (401, 403, 400, 429), only these count towards maintenance
+	Unhealthy bool `json:"unhealthy"`
+}
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+type CircuitBreakerStatus struct {
+	Scope               string       `json:"scope"` // "provider" or "model"
+	ID                  uint         `json:"id"`
+	Name                string       `json:"name"`
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Requests            int          `json:"requests"` // in the current window
+	Failures            int          `json:"failures"` // in the current window
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
 }
@@ -33,6 +33,8 @@ type User struct {
 	MonthlyRequestLimit int64   `json:"monthly_request_limit" gorm:"default:10000"`
 	DailyCostLimit      float64 `json:"daily_cost_limit" gorm:"default:10.0"`
 	MonthlyCostLimit    float64 `json:"monthly_cost_limit" gorm:"default:100.0"`
+	RequestsPerMinute   int     `json:"requests_per_minute" gorm:"default:0"` // across all keys, 0 is unlimited
+	TokensPerMinute     int     `json:"tokens_per_minute" gorm:"default:0"`
 
 	// Relationships
 	APIKeys   []APIKey   `json:"api_keys,omitempty" gorm:"foreignKey:UserID"`
@@ -72,6 +74,8 @@ type UpdateUserRequest struct {
 	MonthlyRequestLimit *int64      `json:"monthly_request_limit,omitempty"`
 	DailyCostLimit      *float64    `json:"daily_cost_limit,omitempty"`
 	MonthlyCostLimit    *float64    `json:"monthly_cost_limit,omitempty"`
+	RequestsPerMinute   *int        `json:"requests_per_minute,omitempty"`
+	TokensPerMinute     *int        `json:"tokens_per_minute,omitempty"`
 }
 
 type LoginRequest struct {
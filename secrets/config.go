@@ -0,0 +1,26 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"llm-inferra/internal/config"
+)
+
+// FromConfig loads the keyring configured in cfg. Outside production a missing configuration
+// falls back to a key derived from the JWT secret, so local setups work without extra settings.
+func FromConfig(cfg *config.Config) (*Keyring, error) {
+	enc := cfg.Encryption
+	if enc.MasterKeys != "" || enc.KeyFile != "" {
+		return LoadKeyring(enc.MasterKeys, enc.KeyFile, enc.CurrentVersion)
+	}
+
+	if cfg.Environment == "production" {
+		return nil, fmt.Errorf("no encryption master key configured: set ENCRYPTION_MASTER_KEYS or ENCRYPTION_KEY_FILE")
+	}
+
+	// TODO: Replace with proper logger
+	fmt.Println("Warning: no encryption master key configured, using a development key derived from JWT_SECRET")
+	devKey := sha256.Sum256([]byte("llm-inferra development master key:" + cfg.JWTSecret))
+	return NewKeyring(map[int][]byte{1: devKey[:]}, 1)
+}
@@ -0,0 +1,296 @@
+package secrets
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encrypted values look like enc:v<version>:<wrapped data key>:<ciphertext>. Each value has its own
+// random data key, encrypted ("wrapped") with the master key of the given version, so rotating the
+// master key only re-wraps data keys and never needs the plaintext re-encrypted.
+const encryptedPrefix = "enc:v"
+
+// Keyring holds the versioned master keys. New values are always wrapped with the current version,
+// older versions are kept so existing values can still be read until they are re-encrypted.
+type Keyring struct {
+	keys    map[int][]byte
+	current int
+}
+
+// NewKeyring builds a keyring from 32-byte AES-256 master keys. A current version of 0 selects the
+// highest version.
+func NewKeyring(keys map[int][]byte, current int) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no encryption master keys configured")
+	}
+
+	highest := 0
+	for version, key := range keys {
+		if version <= 0 {
+			return nil, fmt.Errorf("invalid master key version %d: versions start at 1", version)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid master key version %d: must be 32 bytes, got %d", version, len(key))
+		}
+		if version > highest {
+			highest = version
+		}
+	}
+
+	if current == 0 {
+		current = highest
+	}
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("master key version %d is not configured", current)
+	}
+
+	return &Keyring{keys: keys, current: current}, nil
+}
+
+// LoadKeyring reads master keys from a comma separated "version:base64key" list and from a key
+// file with one "version:base64key" entry per line (blank lines and # comments are ignored).
+func LoadKeyring(spec, keyFile string, current int) (*Keyring, error) {
+	keys := make(map[int][]byte)
+
+	for _, entry := range strings.Split(spec, ",") {
+		if err := addKeyEntry(keys, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if keyFile != "" {
+		file, err := os.Open(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open master key file: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if err := addKeyEntry(keys, scanner.Text()); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read master key file: %w", err)
+		}
+	}
+
+	return NewKeyring(keys, current)
+}
+
+func addKeyEntry(keys map[int][]byte, entry string) error {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || strings.HasPrefix(entry, "#") {
+		return nil
+	}
+
+	versionText, encoded, ok := strings.Cut(entry, ":")
+	if !ok {
+		return fmt.Errorf("invalid master key entry: expected version:base64key")
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(versionText))
+	if err != nil {
+		return fmt.Errorf("invalid master key version %q", versionText)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return fmt.Errorf("invalid master key version %d: %w", version, err)
+	}
+	if _, exists := keys[version]; exists {
+		return fmt.Errorf("master key version %d is configured more than once", version)
+	}
+
+	keys[version] = key
+	return nil
+}
+
+// CurrentVersion returns the master key version new values are wrapped with
+func (k *Keyring) CurrentVersion() int {
+	return k.current
+}
+
+// Encrypt encrypts plaintext with a fresh data key wrapped by the current master key
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, err := seal(k.keys[k.current], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%d:%s:%s", encryptedPrefix, k.current,
+		base64.RawStdEncoding.EncodeToString(wrapped),
+		base64.RawStdEncoding.EncodeToString(ciphertext)), nil
+}
+
+// Decrypt returns the plaintext of an encrypted value. Values that are not encrypted (rows written
+// before encryption was enabled) are returned unchanged.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	_, dataKey, ciphertext, err := k.unwrap(value)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap returns value with its data key wrapped by the current master key, encrypting it first if
+// it is still plaintext. changed is false when value was already under the current key.
+func (k *Keyring) Rewrap(value string) (rewrapped string, changed bool, err error) {
+	if !IsEncrypted(value) {
+		rewrapped, err = k.Encrypt(value)
+		return rewrapped, err == nil, err
+	}
+
+	version, dataKey, ciphertext, err := k.unwrap(value)
+	if err != nil {
+		return "", false, err
+	}
+	if version == k.current {
+		return value, false, nil
+	}
+
+	wrapped, err := seal(k.keys[k.current], dataKey)
+	if err != nil {
+		return "", false, err
+	}
+	return fmt.Sprintf("%s%d:%s:%s", encryptedPrefix, k.current,
+		base64.RawStdEncoding.EncodeToString(wrapped),
+		base64.RawStdEncoding.EncodeToString(ciphertext)), true, nil
+}
+
+// unwrap parses an encrypted value and decrypts its data key
+func (k *Keyring) unwrap(value string) (version int, dataKey, ciphertext []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return 0, nil, nil, fmt.Errorf("malformed encrypted value")
+	}
+
+	version, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("malformed encrypted value: bad key version")
+	}
+	masterKey, ok := k.keys[version]
+	if !ok {
+		return 0, nil, nil, fmt.Errorf("master key version %d is not configured", version)
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	ciphertext, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("malformed encrypted value: %w", err)
+	}
+
+	dataKey, err = open(masterKey, wrapped)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to unwrap data key with master key version %d: %w", version, err)
+	}
+	return version, dataKey, ciphertext, nil
+}
+
+// IsEncrypted reports whether value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Digest returns the hex SHA-256 of value, used to look up keys without storing them in the clear
+func Digest(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// DisplayPrefix returns the start of a key to show in listings: its scheme (sk-inferra-, sk-ant-,
+// ...) and the next four characters. Keys too short to spare them only show the scheme.
+func DisplayPrefix(key string) string {
+	scheme := ""
+	for _, candidate := range []string{"sk-inferra-", "sk-ant-", "sk-proj-", "sk-"} {
+		if strings.HasPrefix(key, candidate) {
+			scheme = candidate
+			break
+		}
+	}
+	if len(key)-len(scheme) < 16 {
+		return scheme
+	}
+	return key[:len(scheme)+4]
+}
+
+// seal encrypts with AES-256-GCM, prefixing the random nonce
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultKeyring *Keyring
+)
+
+// SetDefault installs the keyring used by the "encrypted" gorm serializer
+func SetDefault(keyring *Keyring) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultKeyring = keyring
+}
+
+// Default returns the keyring installed with SetDefault, nil if encryption is not configured
+func Default() *Keyring {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultKeyring
+}
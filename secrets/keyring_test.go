@@ -0,0 +1,163 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testMasterKey(fill byte) []byte {
+	return bytes.Repeat([]byte{fill}, 32)
+}
+
+func testKeyring(t *testing.T, keys map[int][]byte, current int) *Keyring {
+	t.Helper()
+
+	keyring, err := NewKeyring(keys, current)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return keyring
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	keyring := testKeyring(t, map[int][]byte{1: testMasterKey(1)}, 0)
+
+	encrypted, err := keyring.Encrypt("sk-ant-secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "sk-ant-secret") {
+		t.Fatalf("Encrypt returned %q", encrypted)
+	}
+
+	decrypted, err := keyring.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if decrypted != "sk-ant-secret" {
+		t.Fatalf("Decrypt = %q, want %q", decrypted, "sk-ant-secret")
+	}
+}
+
+func TestRewrapToCurrentVersion(t *testing.T) {
+	keys := map[int][]byte{1: testMasterKey(1), 2: testMasterKey(2)}
+	old := testKeyring(t, keys, 1)
+	current := testKeyring(t, keys, 2)
+
+	encrypted, err := old.Encrypt("sk-secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	// The current keyring still reads values wrapped with version 1
+	if decrypted, err := current.Decrypt(encrypted); err != nil || decrypted != "sk-secret" {
+		t.Fatalf("Decrypt old version = %q, %v", decrypted, err)
+	}
+
+	rewrapped, changed, err := current.Rewrap(encrypted)
+	if err != nil {
+		t.Fatalf("Rewrap: %v", err)
+	}
+	if !changed || !strings.HasPrefix(rewrapped, "enc:v2:") {
+		t.Fatalf("Rewrap = %q, changed %v; want a version 2 value", rewrapped, changed)
+	}
+	if decrypted, err := current.Decrypt(rewrapped); err != nil || decrypted != "sk-secret" {
+		t.Fatalf("Decrypt rewrapped = %q, %v", decrypted, err)
+	}
+
+	again, changed, err := current.Rewrap(rewrapped)
+	if err != nil || changed || again != rewrapped {
+		t.Fatalf("Rewrap of a current value = %q, changed %v, %v", again, changed, err)
+	}
+}
+
+func TestPlaintextPassThrough(t *testing.T) {
+	keyring := testKeyring(t, map[int][]byte{1: testMasterKey(1)}, 0)
+
+	decrypted, err := keyring.Decrypt("sk-legacy")
+	if err != nil || decrypted != "sk-legacy" {
+		t.Fatalf("Decrypt plaintext = %q, %v", decrypted, err)
+	}
+
+	// Rewrap encrypts values written before encryption was enabled
+	rewrapped, changed, err := keyring.Rewrap("sk-legacy")
+	if err != nil || !changed || !IsEncrypted(rewrapped) {
+		t.Fatalf("Rewrap plaintext = %q, changed %v, %v", rewrapped, changed, err)
+	}
+	if decrypted, err := keyring.Decrypt(rewrapped); err != nil || decrypted != "sk-legacy" {
+		t.Fatalf("Decrypt rewrapped = %q, %v", decrypted, err)
+	}
+}
+
+func TestDecryptRejectsTamperedValue(t *testing.T) {
+	keyring := testKeyring(t, map[int][]byte{1: testMasterKey(1)}, 0)
+
+	encrypted, err := keyring.Encrypt("sk-secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	parts := strings.Split(encrypted, ":")
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		t.Fatalf("decode ciphertext: %v", err)
+	}
+	ciphertext[len(ciphertext)-1] ^= 0xff
+	parts[3] = base64.RawStdEncoding.EncodeToString(ciphertext)
+
+	if _, err := keyring.Decrypt(strings.Join(parts, ":")); err == nil {
+		t.Fatal("Decrypt accepted a tampered ciphertext")
+	}
+
+	other := testKeyring(t, map[int][]byte{1: testMasterKey(9)}, 0)
+	if _, err := other.Decrypt(encrypted); err == nil {
+		t.Fatal("Decrypt accepted a value wrapped with another master key")
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	key1 := base64.StdEncoding.EncodeToString(testMasterKey(1))
+	key2 := base64.StdEncoding.EncodeToString(testMasterKey(2))
+
+	keyFile := filepath.Join(t.TempDir(), "master.keys")
+	if err := os.WriteFile(keyFile, []byte("# rotated 2026-10\n\n2:"+key2+"\n"), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+
+	keyring, err := LoadKeyring("1:"+key1, keyFile, 0)
+	if err != nil {
+		t.Fatalf("LoadKeyring: %v", err)
+	}
+	if keyring.CurrentVersion() != 2 {
+		t.Fatalf("CurrentVersion = %d, want 2", keyring.CurrentVersion())
+	}
+
+	tests := []struct {
+		name    string
+		spec    string
+		keyFile string
+	}{
+		{name: "duplicate version", spec: "1:" + key1 + ",1:" + key2},
+		{name: "duplicate across spec and file", spec: "2:" + key1, keyFile: keyFile},
+		{name: "missing separator", spec: key1},
+		{name: "bad version", spec: "one:" + key1},
+		{name: "version zero", spec: "0:" + key1},
+		{name: "bad base64", spec: "1:not-base64!"},
+		{name: "short key", spec: "1:" + base64.StdEncoding.EncodeToString([]byte("short"))},
+		{name: "no keys", spec: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadKeyring(tt.spec, tt.keyFile, 0); err == nil {
+				t.Fatal("LoadKeyring accepted invalid keys")
+			}
+		})
+	}
+
+	if _, err := LoadKeyring("1:"+key1, "", 3); err == nil {
+		t.Fatal("LoadKeyring accepted an unconfigured current version")
+	}
+}
@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// EncryptedSerializer stores string fields encrypted with the default keyring. Use it with
+// `gorm:"serializer:encrypted"`. Plaintext values already in the table are still read, so
+// encryption can be enabled on an existing database.
+type EncryptedSerializer struct{}
+
+// Scan implements schema.SerializerInterface
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		stored = string(v)
+	case string:
+		stored = v
+	default:
+		return fmt.Errorf("unsupported type %T for encrypted field %s", dbValue, field.Name)
+	}
+
+	plaintext := stored
+	if IsEncrypted(stored) {
+		keyring := Default()
+		if keyring == nil {
+			return fmt.Errorf("cannot decrypt %s: no encryption master key configured", field.Name)
+		}
+
+		var err error
+		plaintext, err = keyring.Decrypt(stored)
+		if err != nil {
+			return fmt.Errorf("cannot decrypt %s: %w", field.Name, err)
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+// Value implements schema.SerializerValuerInterface
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %T for encrypted field %s", fieldValue, field.Name)
+	}
+	if plaintext == "" {
+		return "", nil
+	}
+
+	keyring := Default()
+	if keyring == nil {
+		return nil, fmt.Errorf("cannot store %s: no encryption master key configured (set ENCRYPTION_MASTER_KEYS or ENCRYPTION_KEY_FILE)", field.Name)
+	}
+	return keyring.Encrypt(plaintext)
+}
@@ -8,13 +8,19 @@ import (
 )
 
 type AnalyticsService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	breakers *CircuitBreakerRegistry
 }
 
 func NewAnalyticsService(db *gorm.DB) *AnalyticsService {
 	return &AnalyticsService{db: db}
 }
 
+// SetCircuitBreakers lets system health report live circuit breaker state
+func (s *AnalyticsService) SetCircuitBreakers(breakers *CircuitBreakerRegistry) {
+	s.breakers = breakers
+}
+
 func (s *AnalyticsService) GetOverview() (*models.UsageAnalytics, error) {
 	// Get overview analytics from the last 30 days
 	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
@@ -124,9 +130,72 @@ func (s *AnalyticsService) GetProviderAnalytics() ([]models.ProviderMetric, erro
 		return nil, err
 	}
 
+	if err := s.addProviderUptime(&providerMetrics); err != nil {
+		return nil, err
+	}
+
 	return providerMetrics, nil
 }
 
+// addProviderUptime fills in uptime and probe latency from the last 24 hours of health probes,
+// adding probed providers that had no traffic
+func (s *AnalyticsService) addProviderUptime(providerMetrics *[]models.ProviderMetric) error {
+	var uptimes []struct {
+		ProviderID   uint
+		ProviderName string
+		Uptime       float64
+		LatencyMs    float64
+		LastProbeAt  time.Time
+	}
+	if err := s.db.Raw(`
+		SELECT 
+			pp.provider_id as provider_id,
+			p.name as provider_name,
+			AVG(CASE WHEN pp.success = true THEN 100.0 ELSE 0.0 END) as uptime,
+			AVG(pp.latency_ms) as latency_ms,
+			MAX(pp.created_at) as last_probe_at
+		FROM provider_probes pp
+		JOIN providers p ON p.id = pp.provider_id
+		WHERE pp.created_at >= ?
+		GROUP BY pp.provider_id, p.name
+	`, time.Now().Add(-24*time.Hour)).Scan(&uptimes).Error; err != nil {
+		return err
+	}
+
+	metrics := *providerMetrics
+	for _, uptime := range uptimes {
+		index := -1
+		for i := range metrics {
+			if metrics[i].ProviderID == uptime.ProviderID {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			metrics = append(metrics, models.ProviderMetric{ProviderID: uptime.ProviderID, ProviderName: uptime.ProviderName})
+			index = len(metrics) - 1
+		}
+
+		// Status of the most recent probe
+		var lastProbe models.ProviderProbe
+		if err := s.db.Where("provider_id = ?", uptime.ProviderID).Order("created_at DESC").First(&lastProbe).Error; err == nil {
+			metrics[index].LastProbeStatus = "down"
+			if lastProbe.Success {
+				metrics[index].LastProbeStatus = "up"
+			}
+		}
+
+		value := uptime.Uptime
+		lastProbeAt := uptime.LastProbeAt
+		metrics[index].Uptime = &value
+		metrics[index].ProbeLatencyMs = uptime.LatencyMs
+		metrics[index].LastProbeAt = &lastProbeAt
+	}
+
+	*providerMetrics = metrics
+	return nil
+}
+
 func (s *AnalyticsService) GetModelAnalytics() ([]models.ModelMetric, error) {
 	var modelMetrics []models.ModelMetric
 
@@ -150,6 +219,68 @@ func (s *AnalyticsService) GetModelAnalytics() ([]models.ModelMetric, error) {
 	return modelMetrics, nil
 }
 
+// GetCacheAnalytics reports response cache hit rates and saved cost over the last 30 days
+func (s *AnalyticsService) GetCacheAnalytics() (*models.CacheAnalytics, error) {
+	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
+
+	var daily []struct {
+		Date         string
+		ExactHits    int64
+		SemanticHits int64
+		Misses       int64
+		Bypassed     int64
+		SavedCost    float64
+	}
+	if err := s.db.Raw(`
+		SELECT 
+			TO_CHAR(created_at, 'YYYY-MM-DD') as date,
+			COALESCE(SUM(CASE WHEN cache_status = ? THEN 1 ELSE 0 END), 0) as exact_hits,
+			COALESCE(SUM(CASE WHEN cache_status = ? THEN 1 ELSE 0 END), 0) as semantic_hits,
+			COALESCE(SUM(CASE WHEN cache_status = ? THEN 1 ELSE 0 END), 0) as misses,
+			COALESCE(SUM(CASE WHEN cache_status = ? THEN 1 ELSE 0 END), 0) as bypassed,
+			COALESCE(SUM(saved_cost), 0) as saved_cost
+		FROM llm_request_logs
+		WHERE created_at >= ? AND cache_status <> ''
+		GROUP BY TO_CHAR(created_at, 'YYYY-MM-DD')
+		ORDER BY date
+	`, ResponseCacheHit, ResponseCacheSemanticHit, ResponseCacheMiss, ResponseCacheBypass, thirtyDaysAgo).Scan(&daily).Error; err != nil {
+		return nil, err
+	}
+
+	analytics := &models.CacheAnalytics{Since: thirtyDaysAgo}
+	for _, day := range daily {
+		hits := day.ExactHits + day.SemanticHits
+		lookups := hits + day.Misses
+
+		analytics.ExactHits += day.ExactHits
+		analytics.SemanticHits += day.SemanticHits
+		analytics.Misses += day.Misses
+		analytics.Bypassed += day.Bypassed
+		analytics.SavedCost += day.SavedCost
+		analytics.Daily = append(analytics.Daily, models.DailyCacheMetric{
+			Date:      day.Date,
+			Lookups:   lookups,
+			Hits:      hits,
+			HitRate:   cacheHitRate(hits, lookups),
+			SavedCost: day.SavedCost,
+		})
+	}
+
+	analytics.Hits = analytics.ExactHits + analytics.SemanticHits
+	analytics.Lookups = analytics.Hits + analytics.Misses
+	analytics.HitRate = cacheHitRate(analytics.Hits, analytics.Lookups)
+	analytics.SemanticHitRate = cacheHitRate(analytics.SemanticHits, analytics.Lookups)
+
+	return analytics, nil
+}
+
+func cacheHitRate(hits, lookups int64) float64 {
+	if lookups == 0 {
+		return 0
+	}
+	return float64(hits) / float64(lookups)
+}
+
 func (s *AnalyticsService) GetSystemHealth() (*models.SystemHealth, error) {
 	// Calculate system health metrics
 	now := time.Now()
@@ -194,18 +325,28 @@ func (s *AnalyticsService) GetSystemHealth() (*models.SystemHealth, error) {
 		requestsPerSecond = float64(totalRequests) / 3600.0 // requests per second in the last hour
 	}
 
-	// Count online/offline providers (simplified)
+	// Count online/offline providers: a provider is online when it is active and its circuit is not open
+	var providers []models.Provider
+	if err := s.db.Select("id", "status").Find(&providers).Error; err != nil {
+		providers = nil
+	}
+
 	var onlineProviders, offlineProviders int64
-	if err := s.db.Model(&models.Provider{}).
-		Where("status = ?", "active").
-		Count(&onlineProviders).Error; err != nil {
-		onlineProviders = 0
+	for _, provider := range providers {
+		online := provider.Status == models.ProviderStatusActive
+		if online && s.breakers != nil && s.breakers.ProviderState(provider.ID) == models.CircuitOpen {
+			online = false
+		}
+		if online {
+			onlineProviders++
+		} else {
+			offlineProviders++
+		}
 	}
 
-	if err := s.db.Model(&models.Provider{}).
-		Where("status != ? OR status IS NULL", "active").
-		Count(&offlineProviders).Error; err != nil {
-		offlineProviders = 0
+	var circuitBreakers []models.CircuitBreakerStatus
+	if s.breakers != nil {
+		circuitBreakers = s.breakers.Snapshot()
 	}
 
 	// Get database connection count (simplified)
@@ -227,6 +368,7 @@ func (s *AnalyticsService) GetSystemHealth() (*models.SystemHealth, error) {
 		DatabaseLatency:     0, // Would need to measure actual DB latency
 		ProvidersOnline:     int(onlineProviders),
 		ProvidersOffline:    int(offlineProviders),
+		CircuitBreakers:     circuitBreakers,
 	}, nil
 }
 
@@ -20,9 +20,7 @@ type AnthropicProvider struct {
 
 func NewAnthropicProvider(baseURL, apiVersion string) *AnthropicProvider {
 	return &AnthropicProvider{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		httpClient: newUpstreamHTTPClient(defaultResponseHeaderTimeout),
 		baseURL:    baseURL,
 		apiVersion: apiVersion,
 	}
@@ -37,13 +35,13 @@ func (ap *AnthropicProvider) ValidateRequest(req *models.ChatCompletionRequest)
 		return fmt.Errorf("messages are required")
 	}
 
-	// Validate message roles for Anthropic
+	// Validate message roles for Anthropic, tool results are sent back as user turns
 	for i, msg := range req.Messages {
-		if msg.Role != "user" && msg.Role != "assistant" {
-			return fmt.Errorf("message %d: role must be 'user' or 'assistant' for Anthropic", i)
+		if msg.Role != "user" && msg.Role != "assistant" && msg.Role != "tool" {
+			return fmt.Errorf("message %d: role must be 'user', 'assistant' or 'tool' for Anthropic", i)
 		}
-		if msg.Content == "" {
-			return fmt.Errorf("message %d: content cannot be empty", i)
+		if err := validateToolMessage(i, msg); err != nil {
+			return err
 		}
 	}
 
@@ -52,7 +50,7 @@ func (ap *AnthropicProvider) ValidateRequest(req *models.ChatCompletionRequest)
 		return fmt.Errorf("first message must be from user for Anthropic")
 	}
 
-	return nil
+	return validateTools(req)
 }
 
 // 函数的核心功能是将标准化的聊天完成请求格式转换为 Anthropic API 特定的请求格式
@@ -62,7 +60,7 @@ func (ap *AnthropicProvider) ValidateRequest(req *models.ChatCompletionRequest)
 func (ap *AnthropicProvider) TransformRequest(req *models.ChatCompletionRequest) (interface{}, error) {
 	anthropicReq := map[string]interface{}{
 		"model":    req.Model,
-		"messages": req.Messages,
+		"messages": ap.transformMessages(req.Messages),
 	}
 
 	// Set max_tokens (required for Anthropic)
@@ -81,6 +79,10 @@ func (ap *AnthropicProvider) TransformRequest(req *models.ChatCompletionRequest)
 		anthropicReq["top_p"] = *req.TopP
 	}
 
+	if req.TopK != nil {
+		anthropicReq["top_k"] = *req.TopK
+	}
+
 	if req.System != "" {
 		anthropicReq["system"] = req.System
 	}
@@ -97,9 +99,93 @@ func (ap *AnthropicProvider) TransformRequest(req *models.ChatCompletionRequest)
 		anthropicReq["metadata"] = req.Metadata
 	}
 
+	// Tool definitions use input_schema instead of OpenAI's parameters
+	if tools := requestTools(req); len(tools) > 0 {
+		anthropicTools := make([]map[string]interface{}, 0, len(tools))
+		for _, tool := range tools {
+			anthropicTool := map[string]interface{}{
+				"name":         tool.Function.Name,
+				"input_schema": toolSchema(tool.Function.Parameters),
+			}
+			if tool.Function.Description != "" {
+				anthropicTool["description"] = tool.Function.Description
+			}
+			anthropicTools = append(anthropicTools, anthropicTool)
+		}
+		anthropicReq["tools"] = anthropicTools
+
+		switch mode, name := requestToolChoice(req); mode {
+		case "required":
+			anthropicReq["tool_choice"] = map[string]interface{}{"type": "any"}
+		case "function":
+			anthropicReq["tool_choice"] = map[string]interface{}{"type": "tool", "name": name}
+		case "none":
+			anthropicReq["tool_choice"] = map[string]interface{}{"type": "none"}
+		default:
+			anthropicReq["tool_choice"] = map[string]interface{}{"type": "auto"}
+		}
+	}
+
 	return anthropicReq, nil
 }
 
+// transformMessages converts unified messages into Anthropic messages.
+// Assistant tool calls become tool_use blocks, and consecutive "tool" messages are merged
+// into a single user turn of tool_result blocks as Anthropic requires.
+func (ap *AnthropicProvider) transformMessages(messages []models.ChatMessage) []map[string]interface{} {
+	anthropicMessages := make([]map[string]interface{}, 0, len(messages))
+	lastWasToolResult := false
+
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "tool":
+			block := map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": msg.ToolCallID,
+				"content":     anthropicContent(msg.Content),
+			}
+			if lastWasToolResult {
+				last := anthropicMessages[len(anthropicMessages)-1]
+				last["content"] = append(last["content"].([]map[string]interface{}), block)
+			} else {
+				anthropicMessages = append(anthropicMessages, map[string]interface{}{
+					"role":    "user",
+					"content": []map[string]interface{}{block},
+				})
+			}
+			lastWasToolResult = true
+			continue
+
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			blocks := make([]map[string]interface{}, 0, len(msg.ToolCalls)+1)
+			if text := msg.Content.String(); text != "" {
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+			}
+			for _, call := range msg.ToolCalls {
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    call.ID,
+					"name":  call.Function.Name,
+					"input": toolArguments(call.Function.Arguments),
+				})
+			}
+			anthropicMessages = append(anthropicMessages, map[string]interface{}{
+				"role":    "assistant",
+				"content": blocks,
+			})
+
+		default:
+			anthropicMessages = append(anthropicMessages, map[string]interface{}{
+				"role":    msg.Role,
+				"content": anthropicContent(msg.Content),
+			})
+		}
+		lastWasToolResult = false
+	}
+
+	return anthropicMessages
+}
+
 // 函数的核心功能是将 Anthropic API 的原生响应格式转换为系统统一的标准响应格式。
 // 使用Go的类型断言（type assertion）将通用接口 interface{} 转换为具体的 *models.AnthropicResponse 类型 ；然后进行基础字段映射；还做了openai的兼容性处理；最后返回转换后的标准响应结构。
 // 意图是保持对不同供应商（OpenAI、Anthropic等）的响应兼容处理，确保LLM-inferra 可以支持不同的LLM供应商，并保持一个统一的调用接口。
@@ -125,9 +211,29 @@ func (ap *AnthropicProvider) TransformResponse(resp interface{}) (*models.ChatCo
 		},
 	}
 
-	// Transform content
+	// Transform content, collecting text and tool_use blocks for the OpenAI-style message
+	var text strings.Builder
+	var toolCalls []models.ToolCall
 	for _, content := range anthropicResp.Content {
 		response.Content = append(response.Content, models.ChatCompletionContent(content))
+
+		switch content.Type {
+		case "text":
+			text.WriteString(content.Text)
+		case "tool_use":
+			arguments := string(content.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			toolCalls = append(toolCalls, models.ToolCall{
+				ID:   content.ID,
+				Type: "function",
+				Function: models.ToolCallFunction{
+					Name:      content.Name,
+					Arguments: arguments,
+				},
+			})
+		}
 	}
 
 	// For OpenAI compatibility - create choices array
@@ -135,10 +241,11 @@ func (ap *AnthropicProvider) TransformResponse(resp interface{}) (*models.ChatCo
 		choice := models.ChatCompletionChoice{
 			Index: 0,
 			Message: models.ChatMessage{
-				Role:    anthropicResp.Role,
-				Content: anthropicResp.Content[0].Text,
+				Role:      anthropicResp.Role,
+				Content:   models.TextContent(text.String()),
+				ToolCalls: toolCalls,
 			},
-			FinishReason: anthropicResp.StopReason,
+			FinishReason: anthropicFinishReason(anthropicResp.StopReason),
 		}
 		response.Choices = []models.ChatCompletionChoice{choice}
 		response.Object = "chat.completion"
@@ -174,7 +281,7 @@ func (ap *AnthropicProvider) ChatCompletion(ctx *models.LLMRequestContext, req *
 
 	// Set headers
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", ctx.APIKey.KeyValue)
+	httpReq.Header.Set("x-api-key", upstreamKey(ctx))
 	httpReq.Header.Set("anthropic-version", ap.apiVersion)
 
 	if req.AnthropicVersion != "" {
@@ -196,7 +303,7 @@ func (ap *AnthropicProvider) ChatCompletion(ctx *models.LLMRequestContext, req *
 
 	// Handle non-2xx status codes
 	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
-		return nil, fmt.Errorf("API request failed with status %d: %s", httpResp.StatusCode, string(respBody))
+		return nil, newUpstreamError(httpResp, respBody)
 	}
 
 	// Parse Anthropic response
@@ -250,7 +357,7 @@ func (ap *AnthropicProvider) StreamChatCompletion(ctx *models.LLMRequestContext,
 
 	// Set headers for streaming
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", ctx.APIKey.KeyValue)
+	httpReq.Header.Set("x-api-key", upstreamKey(ctx))
 	httpReq.Header.Set("anthropic-version", ap.apiVersion)
 	httpReq.Header.Set("Accept", "text/event-stream")
 	httpReq.Header.Set("Cache-Control", "no-cache")
@@ -269,7 +376,7 @@ func (ap *AnthropicProvider) StreamChatCompletion(ctx *models.LLMRequestContext,
 	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
 		defer httpResp.Body.Close()
 		respBody, _ := io.ReadAll(httpResp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", httpResp.StatusCode, string(respBody))
+		return nil, newUpstreamError(httpResp, respBody)
 	}
 
 	// Create channel for streaming response
@@ -279,6 +386,8 @@ func (ap *AnthropicProvider) StreamChatCompletion(ctx *models.LLMRequestContext,
 		defer close(streamChan)
 		defer httpResp.Body.Close()
 
+		translator := newAnthropicStreamTranslator(req)
+
 		buffer := make([]byte, 4096)
 		remainder := ""
 
@@ -299,8 +408,8 @@ func (ap *AnthropicProvider) StreamChatCompletion(ctx *models.LLMRequestContext,
 						break
 					}
 
-					// 4. 处理事件：处理每个事件，提取usage信息，并返回处理后的数据
-					if processedData := ap.processSSEEvent(event); processedData != nil {
+					// 4. 处理事件：转换为 OpenAI chunk（或原样透传），提取usage信息
+					for _, processedData := range translator.translate(event) {
 						streamChan <- processedData
 					}
 				}
@@ -331,63 +440,18 @@ func (ap *AnthropicProvider) parseSSEEvents(text string) []string {
 	return events
 }
 
-// AnthropicStreamEvent represents different types of streaming events
-type AnthropicStreamEvent struct {
-	Type  string          `json:"type"`
-	Delta json.RawMessage `json:"delta,omitempty"`
-	Usage *struct {
-		InputTokens  int `json:"input_tokens"`
-		OutputTokens int `json:"output_tokens"`
-	} `json:"usage,omitempty"`
-	Message *struct {
-		Usage *struct {
-			InputTokens  int `json:"input_tokens"`
-			OutputTokens int `json:"output_tokens"`
-		} `json:"usage,omitempty"`
-	} `json:"message,omitempty"`
-}
-
-// processSSEEvent processes individual SSE events and extracts usage information
-func (ap *AnthropicProvider) processSSEEvent(eventText string) []byte {
-	lines := strings.Split(strings.TrimSpace(eventText), "\n")
-
-	for _, line := range lines {
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
-
-			// Skip empty data or [DONE] markers
-			if data == "" || data == "[DONE]" {
-				continue
-			}
-
-			// Try to parse as Anthropic stream event
-			var streamEvent AnthropicStreamEvent
-			if err := json.Unmarshal([]byte(data), &streamEvent); err == nil {
-				// Check for usage information in different event types
-				if streamEvent.Type == "message_stop" && streamEvent.Message != nil && streamEvent.Message.Usage != nil {
-					// Extract usage information from final event
-					usage := streamEvent.Message.Usage
-					usageEvent := map[string]interface{}{
-						"type": "usage_update",
-						"usage": map[string]int{
-							"input_tokens":  usage.InputTokens,
-							"output_tokens": usage.OutputTokens,
-							"total_tokens":  usage.InputTokens + usage.OutputTokens,
-						},
-					}
-
-					// Convert back to SSE format
-					usageData, _ := json.Marshal(usageEvent)
-					return []byte(fmt.Sprintf("data: %s\n\n", string(usageData)))
-				}
-			}
-
-			// Return original data for other events (content deltas, etc.)
-			return []byte(fmt.Sprintf("data: %s\n\n", data))
-		}
+// anthropicFinishReason maps Anthropic stop reasons onto their OpenAI equivalents
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return stopReason
 	}
-
-	return nil
 }
 
 // Helper function to calculate cost
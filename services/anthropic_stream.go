@@ -0,0 +1,203 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"llm-inferra/internal/models"
+)
+
+// AnthropicStreamEvent represents different types of streaming events
+type AnthropicStreamEvent struct {
+	Type         string                   `json:"type"`
+	Index        int                      `json:"index"`
+	ContentBlock *models.AnthropicContent `json:"content_block,omitempty"`
+	Delta        *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text,omitempty"`
+		PartialJSON string `json:"partial_json,omitempty"`
+		StopReason  string `json:"stop_reason,omitempty"`
+	} `json:"delta,omitempty"`
+	Usage   *models.AnthropicUsage `json:"usage,omitempty"`
+	Message *struct {
+		ID    string                 `json:"id"`
+		Model string                 `json:"model"`
+		Usage *models.AnthropicUsage `json:"usage,omitempty"`
+	} `json:"message,omitempty"`
+	Error json.RawMessage `json:"error,omitempty"`
+}
+
+// anthropicStreamTranslator converts Anthropic SSE events into OpenAI chat.completion.chunk
+// events (or passes them through untouched for the native format) and tracks token usage
+// so a usage_update event can be emitted at the end of the stream
+type anthropicStreamTranslator struct {
+	format       string
+	includeUsage bool
+
+	id      string
+	model   string
+	created int64
+
+	inputTokens  int
+	outputTokens int
+
+	// Anthropic content block index -> OpenAI tool call index
+	toolCallIndex map[int]int
+}
+
+func newAnthropicStreamTranslator(req *models.ChatCompletionRequest) *anthropicStreamTranslator {
+	format := req.StreamFormat
+	if format == "" {
+		format = models.StreamFormatOpenAI
+	}
+	return &anthropicStreamTranslator{
+		format:        format,
+		includeUsage:  includeUsage(req),
+		model:         req.Model,
+		created:       time.Now().Unix(),
+		toolCallIndex: make(map[int]int),
+	}
+}
+
+// translate processes a single SSE event and returns the events to forward to the client
+func (t *anthropicStreamTranslator) translate(eventText string) [][]byte {
+	var eventType, data string
+	for _, line := range strings.Split(strings.TrimSpace(eventText), "\n") {
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+
+	// Skip empty data or [DONE] markers
+	if data == "" || data == "[DONE]" {
+		return nil
+	}
+
+	var event AnthropicStreamEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		// 无法解析的事件原样透传
+		return [][]byte{[]byte("data: " + data + "\n\n")}
+	}
+	if eventType == "" {
+		eventType = event.Type
+	}
+
+	t.trackUsage(&event)
+
+	if t.format == models.StreamFormatAnthropic {
+		out := [][]byte{[]byte("event: " + eventType + "\ndata: " + data + "\n\n")}
+		if event.Type == "message_stop" {
+			out = append(out, usageUpdateEvent(t.inputTokens, t.outputTokens))
+		}
+		return out
+	}
+
+	return t.toOpenAI(&event)
+}
+
+// trackUsage records input tokens from message_start and output tokens from message_delta
+func (t *anthropicStreamTranslator) trackUsage(event *AnthropicStreamEvent) {
+	switch event.Type {
+	case "message_start":
+		if event.Message != nil {
+			t.id = event.Message.ID
+			if event.Message.Model != "" {
+				t.model = event.Message.Model
+			}
+			if event.Message.Usage != nil {
+				t.inputTokens = event.Message.Usage.InputTokens
+				t.outputTokens = event.Message.Usage.OutputTokens
+			}
+		}
+	case "message_delta":
+		if event.Usage != nil {
+			// message_delta carries the cumulative output token count
+			t.outputTokens = event.Usage.OutputTokens
+			if event.Usage.InputTokens > 0 {
+				t.inputTokens = event.Usage.InputTokens
+			}
+		}
+	}
+}
+
+// toOpenAI maps an Anthropic stream event onto OpenAI chat.completion.chunk events
+func (t *anthropicStreamTranslator) toOpenAI(event *AnthropicStreamEvent) [][]byte {
+	switch event.Type {
+	case "message_start":
+		return [][]byte{t.chunk(map[string]interface{}{"role": "assistant", "content": ""}, nil)}
+
+	case "content_block_start":
+		if event.ContentBlock == nil || event.ContentBlock.Type != "tool_use" {
+			return nil
+		}
+		index := len(t.toolCallIndex)
+		t.toolCallIndex[event.Index] = index
+		return [][]byte{t.chunk(map[string]interface{}{
+			"tool_calls": []models.ToolCall{{
+				Index:    &index,
+				ID:       event.ContentBlock.ID,
+				Type:     "function",
+				Function: models.ToolCallFunction{Name: event.ContentBlock.Name},
+			}},
+		}, nil)}
+
+	case "content_block_delta":
+		if event.Delta == nil {
+			return nil
+		}
+		switch event.Delta.Type {
+		case "text_delta":
+			return [][]byte{t.chunk(map[string]interface{}{"content": event.Delta.Text}, nil)}
+		case "input_json_delta":
+			index, ok := t.toolCallIndex[event.Index]
+			if !ok {
+				return nil
+			}
+			return [][]byte{t.chunk(map[string]interface{}{
+				"tool_calls": []map[string]interface{}{{
+					"index":    index,
+					"function": map[string]string{"arguments": event.Delta.PartialJSON},
+				}},
+			}, nil)}
+		}
+
+	case "message_delta":
+		if event.Delta == nil || event.Delta.StopReason == "" {
+			return nil
+		}
+		finishReason := anthropicFinishReason(event.Delta.StopReason)
+		return [][]byte{t.chunk(map[string]interface{}{}, &finishReason)}
+
+	case "message_stop":
+		var out [][]byte
+		if t.includeUsage {
+			out = append(out, usageChunk(t.id, t.model, t.created, t.inputTokens, t.outputTokens))
+		}
+		return append(out, usageUpdateEvent(t.inputTokens, t.outputTokens))
+
+	case "error":
+		return [][]byte{sseData(map[string]json.RawMessage{"error": event.Error})}
+	}
+
+	// ping, content_block_stop 等事件无需转发
+	return nil
+}
+
+// chunk builds a chat.completion.chunk event with a single choice
+func (t *anthropicStreamTranslator) chunk(delta map[string]interface{}, finishReason *string) []byte {
+	return sseData(map[string]interface{}{
+		"id":      t.id,
+		"object":  "chat.completion.chunk",
+		"created": t.created,
+		"model":   t.model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	})
+}
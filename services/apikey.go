@@ -1,19 +1,31 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
 	"llm-inferra/internal/models"
+	"llm-inferra/internal/secrets"
 
 	"gorm.io/gorm"
 )
 
 type APIKeyService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	cache *CacheService
 }
 
 func NewAPIKeyService(db *gorm.DB) *APIKeyService {
 	return &APIKeyService{db: db}
 }
 
+// SetCache lets key changes drop the cached copy used for request authentication
+func (s *APIKeyService) SetCache(cache *CacheService) {
+	s.cache = cache
+}
+
 func (s *APIKeyService) List(userID uint, offset, limit int) ([]models.APIKey, int64, error) {
 	var apiKeys []models.APIKey
 	var total int64
@@ -37,16 +49,65 @@ func (s *APIKeyService) List(userID uint, offset, limit int) ([]models.APIKey, i
 	return apiKeys, total, nil
 }
 
-func (s *APIKeyService) Create(userID uint, req models.CreateAPIKeyRequest) (*models.APIKey, error) {
+// Create stores a new client key. Without a KeyValue the gateway mints a virtual key, which is
+// returned in the response once and calls upstream with admin-managed credentials. Only the
+// virtual key's digest and display prefix are stored.
+func (s *APIKeyService) Create(userID uint, req models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	if err := validateAPIKeyScopes(req.Scopes); err != nil {
+		return nil, err
+	}
+	if err := validateSemanticCache(req.SemanticCacheThreshold, req.SemanticCacheModel); err != nil {
+		return nil, err
+	}
+
 	apiKey := models.APIKey{
-		UserID:     userID,
-		ProviderID: req.ProviderID,
-		Name:       req.Name,
-		KeyValue:   req.KeyValue, // In production, this should be encrypted
-		Status:     models.APIKeyStatusActive,
+		UserID:              userID,
+		ProviderID:          req.ProviderID,
+		Name:                req.Name,
+		KeyValue:            req.KeyValue, // Pass-through key, encrypted at rest
+		Status:              models.APIKeyStatusActive,
+		ExpiresAt:           req.ExpiresAt,
+		DailyRequestLimit:   req.DailyRequestLimit,
+		MonthlyRequestLimit: req.MonthlyRequestLimit,
+		DailyCostLimit:      req.DailyCostLimit,
+		MonthlyCostLimit:    req.MonthlyCostLimit,
+		RequestsPerMinute:   req.RequestsPerMinute,
+		TokensPerMinute:     req.TokensPerMinute,
+		ResponseCacheTTL:    req.ResponseCacheTTL,
+		AllowedModels:       req.AllowedModels,
+		Scopes:              req.Scopes,
+
+		SemanticCacheThreshold: req.SemanticCacheThreshold,
+		SemanticCacheModel:     req.SemanticCacheModel,
 	}
-	err := s.db.Create(&apiKey).Error
-	return &apiKey, err
+
+	var minted string
+	if req.KeyValue == "" {
+		if err := s.validateUpstreamCredential(req.ProviderID, req.UpstreamCredentialID); err != nil {
+			return nil, err
+		}
+		apiKey.UpstreamCredentialID = req.UpstreamCredentialID
+
+		key, err := generateVirtualKey()
+		if err != nil {
+			return nil, err
+		}
+		minted = key
+		apiKey.Virtual = true
+		apiKey.KeyHash = secrets.Digest(key)
+		apiKey.KeyPrefix = secrets.DisplayPrefix(key)
+	} else {
+		if req.UpstreamCredentialID != nil {
+			return nil, fmt.Errorf("invalid request: upstream_credential_id only applies to virtual keys")
+		}
+		apiKey.KeyHash = secrets.Digest(req.KeyValue)
+		apiKey.KeyPrefix = secrets.DisplayPrefix(req.KeyValue)
+	}
+
+	if err := s.db.Create(&apiKey).Error; err != nil {
+		return nil, err
+	}
+	return &models.CreateAPIKeyResponse{APIKey: apiKey, Key: minted}, nil
 }
 
 func (s *APIKeyService) GetByID(id uint) (*models.APIKey, error) {
@@ -56,9 +117,131 @@ func (s *APIKeyService) GetByID(id uint) (*models.APIKey, error) {
 }
 
 func (s *APIKeyService) Update(id uint, apiKey *models.APIKey) error {
-	return s.db.Save(apiKey).Error
+	existing, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := validateAPIKeyScopes(apiKey.Scopes); err != nil {
+		return err
+	}
+	if err := validateSemanticCache(apiKey.SemanticCacheThreshold, apiKey.SemanticCacheModel); err != nil {
+		return err
+	}
+
+	// A revoked key stays revoked, an omitted status keeps the current one
+	switch {
+	case apiKey.Status == "":
+		apiKey.Status = existing.Status
+	case existing.Status == models.APIKeyStatusRevoked && apiKey.Status != models.APIKeyStatusRevoked:
+		return fmt.Errorf("invalid request: API key %d is revoked", id)
+	case apiKey.Status != models.APIKeyStatusActive && apiKey.Status != models.APIKeyStatusInactive && apiKey.Status != models.APIKeyStatusRevoked:
+		return fmt.Errorf("invalid API key status: %s", apiKey.Status)
+	}
+
+	// The key itself and the provider it belongs to are never changed through an update
+	apiKey.ID = id
+	apiKey.CreatedAt = existing.CreatedAt
+	apiKey.UserID = existing.UserID
+	apiKey.ProviderID = existing.ProviderID
+	apiKey.KeyValue = existing.KeyValue
+	apiKey.KeyHash = existing.KeyHash
+	apiKey.Virtual = existing.Virtual
+	apiKey.KeyPrefix = existing.KeyPrefix
+
+	// Same rules as on Create: a pinned credential is for virtual keys, from the key's provider
+	if apiKey.UpstreamCredentialID != nil && !apiKey.Virtual {
+		return fmt.Errorf("invalid request: upstream_credential_id only applies to virtual keys")
+	}
+	if err := s.validateUpstreamCredential(apiKey.ProviderID, apiKey.UpstreamCredentialID); err != nil {
+		return err
+	}
+
+	if err := s.db.Save(apiKey).Error; err != nil {
+		return err
+	}
+
+	s.invalidate(existing)
+	return nil
+}
+
+// Revoke permanently disables a client key. The upstream credentials it used are unaffected.
+func (s *APIKeyService) Revoke(id uint) (*models.APIKey, error) {
+	apiKey, err := s.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("API key not found: %d", id)
+	}
+
+	if err := s.db.Model(apiKey).Update("status", models.APIKeyStatusRevoked).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	s.invalidate(apiKey)
+	return apiKey, nil
 }
 
 func (s *APIKeyService) Delete(id uint) error {
-	return s.db.Delete(&models.APIKey{}, id).Error
+	existing, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(&models.APIKey{}, id).Error; err != nil {
+		return err
+	}
+
+	s.invalidate(existing)
+	return nil
+}
+
+// invalidate drops the cached authentication entry of a key
+func (s *APIKeyService) invalidate(apiKey *models.APIKey) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.InvalidateAPIKeyAndUsage(context.Background(), apiKey.KeyHash, apiKey.ID); err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to invalidate cached API key: %v\n", err)
+	}
+}
+
+// validateUpstreamCredential checks that a credential pinned to a key belongs to the key's provider
+func (s *APIKeyService) validateUpstreamCredential(providerID uint, credentialID *uint) error {
+	if credentialID == nil {
+		return nil
+	}
+
+	var credential models.ProviderCredential
+	if err := s.db.Where("id = ? AND provider_id = ?", *credentialID, providerID).First(&credential).Error; err != nil {
+		return fmt.Errorf("upstream credential not found: %d", *credentialID)
+	}
+	return nil
+}
+
+// generateVirtualKey returns a new sk-inferra-... key with 192 bits of randomness
+func generateVirtualKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return models.VirtualKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+func validateAPIKeyScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if scope != models.APIKeyScopeChat && scope != models.APIKeyScopeEmbeddings {
+			return fmt.Errorf("invalid API key scope: %s", scope)
+		}
+	}
+	return nil
+}
+
+// validateSemanticCache checks a key's semantic cache settings: a cosine similarity threshold
+// up to 1 and the embedding model to compare with
+func validateSemanticCache(threshold float64, model string) error {
+	if threshold < 0 || threshold > 1 {
+		return fmt.Errorf("invalid semantic cache threshold %g: must be between 0 and 1", threshold)
+	}
+	if threshold > 0 && model == "" {
+		return fmt.Errorf("invalid semantic cache settings: an embedding model is required")
+	}
+	return nil
 }
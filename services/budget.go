@@ -0,0 +1,242 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"llm-inferra/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Spend loaded from the request logs is refreshed this often. Costs of requests that finish in
+// between are added in memory, so the budget is enforced without a query per request.
+const budgetRefreshInterval = 30 * time.Second
+
+// usageWindows returns the bounds of the current day and month used by usage and budget checks
+func usageWindows(now time.Time) (today, tomorrow, monthStart, monthEnd time.Time) {
+	today = now.Truncate(24 * time.Hour)
+	tomorrow = today.Add(24 * time.Hour)
+	monthStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	monthEnd = monthStart.AddDate(0, 1, 0)
+	return
+}
+
+// budgetScope is the spend of one API key or user
+type budgetScope struct {
+	dailySpent   float64 // from the request logs at loadedAt
+	monthlySpent float64
+	day          time.Time
+	loadedAt     time.Time
+
+	settled  float64 // actual cost of requests finished since loadedAt
+	reserved float64 // estimated cost of requests still in flight
+}
+
+// budgetLimit is a cost limit to enforce for a request
+type budgetLimit struct {
+	key     string
+	column  string // llm_request_logs column the scope is aggregated on
+	id      uint
+	label   string
+	daily   float64
+	monthly float64
+}
+
+// BudgetTracker enforces the daily and monthly cost limits of API keys and users. With estimate
+// reservation enabled, the worst-case cost of in-flight requests counts against the budget too,
+// so concurrent requests can't overshoot it.
+type BudgetTracker struct {
+	db *gorm.DB
+
+	mu               sync.Mutex
+	scopes           map[string]*budgetScope
+	reserveEstimates bool
+}
+
+func NewBudgetTracker(db *gorm.DB) *BudgetTracker {
+	return &BudgetTracker{
+		db:               db,
+		scopes:           make(map[string]*budgetScope),
+		reserveEstimates: true,
+	}
+}
+
+// SetReserveEstimates turns reservation of estimated cost for in-flight requests on or off
+func (t *BudgetTracker) SetReserveEstimates(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reserveEstimates = enabled
+}
+
+// budgetReservation holds the estimated cost of one request against its budgets until it finishes
+type budgetReservation struct {
+	tracker  *BudgetTracker
+	keys     []string
+	estimate float64
+	done     bool
+}
+
+// Reserve checks the key and user budgets of a request and reserves its estimated cost. It returns
+// nil when neither has a cost limit.
+func (t *BudgetTracker) Reserve(ctx *models.LLMRequestContext, estimate float64) (*budgetReservation, error) {
+	limits := budgetLimits(ctx)
+	if len(limits) == 0 {
+		return nil, nil
+	}
+
+	for _, limit := range limits {
+		if err := t.refresh(limit); err != nil {
+			return nil, fmt.Errorf("failed to check cost limits: %w", err)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.reserveEstimates {
+		estimate = 0
+	}
+
+	for _, limit := range limits {
+		scope := t.scopes[limit.key]
+		pending := scope.settled + scope.reserved
+
+		if limit.daily > 0 && scope.dailySpent+pending+estimate > limit.daily {
+			return nil, budgetError("daily", limit, scope.dailySpent+pending, limit.daily)
+		}
+		if limit.monthly > 0 && scope.monthlySpent+pending+estimate > limit.monthly {
+			return nil, budgetError("monthly", limit, scope.monthlySpent+pending, limit.monthly)
+		}
+	}
+
+	reservation := &budgetReservation{tracker: t, estimate: estimate}
+	for _, limit := range limits {
+		t.scopes[limit.key].reserved += estimate
+		reservation.keys = append(reservation.keys, limit.key)
+	}
+	return reservation, nil
+}
+
+func budgetError(period string, limit budgetLimit, spent, budget float64) error {
+	if spent >= budget {
+		return fmt.Errorf("%s cost limit exceeded for %s: $%.4f of $%.2f budget spent", period, limit.label, spent, budget)
+	}
+	return fmt.Errorf("%s cost limit exceeded for %s: $%.4f of $%.2f budget spent or reserved, not enough left for this request",
+		period, limit.label, spent, budget)
+}
+
+// budgetLimits returns the cost limits that apply to the request's key and user
+func budgetLimits(ctx *models.LLMRequestContext) []budgetLimit {
+	var limits []budgetLimit
+
+	apiKey := ctx.APIKey
+	if apiKey.DailyCostLimit > 0 || apiKey.MonthlyCostLimit > 0 {
+		limits = append(limits, budgetLimit{
+			key:     fmt.Sprintf("key:%d", apiKey.ID),
+			column:  "api_key_id",
+			id:      apiKey.ID,
+			label:   "API key",
+			daily:   apiKey.DailyCostLimit,
+			monthly: apiKey.MonthlyCostLimit,
+		})
+	}
+
+	user := apiKey.User
+	if user.ID != 0 && (user.DailyCostLimit > 0 || user.MonthlyCostLimit > 0) {
+		limits = append(limits, budgetLimit{
+			key:     fmt.Sprintf("user:%d", user.ID),
+			column:  "user_id",
+			id:      user.ID,
+			label:   "user",
+			daily:   user.DailyCostLimit,
+			monthly: user.MonthlyCostLimit,
+		})
+	}
+
+	return limits
+}
+
+// refresh reloads a scope's spend from the request logs when it is stale or the day has changed
+func (t *BudgetTracker) refresh(limit budgetLimit) error {
+	now := time.Now()
+	today, tomorrow, monthStart, monthEnd := usageWindows(now)
+
+	t.mu.Lock()
+	scope, ok := t.scopes[limit.key]
+	fresh := ok && scope.day.Equal(today) && now.Sub(scope.loadedAt) < budgetRefreshInterval
+	t.mu.Unlock()
+	if fresh {
+		return nil
+	}
+
+	from := monthStart
+	if today.Before(from) {
+		from = today
+	}
+
+	var spent struct {
+		DailyCost   float64
+		MonthlyCost float64
+	}
+	query := fmt.Sprintf(`
+		SELECT
+			COALESCE(SUM(CASE WHEN created_at >= ? AND created_at < ? THEN total_cost ELSE 0 END), 0) AS daily_cost,
+			COALESCE(SUM(CASE WHEN created_at >= ? AND created_at < ? THEN total_cost ELSE 0 END), 0) AS monthly_cost
+		FROM llm_request_logs
+		WHERE %s = ? AND created_at >= ?
+	`, limit.column)
+	if err := t.db.Raw(query, today, tomorrow, monthStart, monthEnd, limit.id, from).Scan(&spent).Error; err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	scope, ok = t.scopes[limit.key]
+	if !ok {
+		scope = &budgetScope{}
+		t.scopes[limit.key] = scope
+	}
+	scope.dailySpent = spent.DailyCost
+	scope.monthlySpent = spent.MonthlyCost
+	scope.day = today
+	scope.loadedAt = now
+	// Finished requests are in the logs now, in-flight reservations carry over
+	scope.settled = 0
+	return nil
+}
+
+// Settle replaces the reservation with the actual cost once the request has finished and its
+// cost has been written to the request log. Safe to call on a nil reservation.
+func (r *budgetReservation) Settle(actualCost float64) {
+	if r == nil {
+		return
+	}
+
+	r.tracker.mu.Lock()
+	defer r.tracker.mu.Unlock()
+
+	if r.done {
+		return
+	}
+	r.done = true
+
+	for _, key := range r.keys {
+		scope, ok := r.tracker.scopes[key]
+		if !ok {
+			continue
+		}
+		scope.reserved -= r.estimate
+		if scope.reserved < 0 {
+			scope.reserved = 0
+		}
+		scope.settled += actualCost
+	}
+}
+
+// Release drops the reservation of a request that failed without cost
+func (r *budgetReservation) Release() {
+	r.Settle(0)
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// defaultMemoryCacheEntries bounds the in-process cache
+	defaultMemoryCacheEntries = 10000
+	// localCacheTTL caps how long an instance keeps its own copy of a Redis entry. Invalidations
+	// reach other instances through pub/sub, this only bounds the damage of a missed message.
+	localCacheTTL = 15 * time.Second
+)
+
+// CacheBackend stores the serialized entries of CacheService. Redis shares them across instances,
+// the in-process LRU needs no external dependency for single instance deployments.
+type CacheBackend interface {
+	// Get returns the value of key, false when it is missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetMany and GetMany batch several keys into one round trip where the backend supports it
+	SetMany(ctx context.Context, entries map[string]cacheValue) error
+	GetMany(ctx context.Context, keys []string) (map[string][]byte, error)
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// cacheValue is one entry of a batched write
+type cacheValue struct {
+	data []byte
+	ttl  time.Duration
+}
+
+// RedisCacheBackend keeps cache entries in Redis
+type RedisCacheBackend struct {
+	client *redis.Client
+}
+
+func NewRedisCacheBackend(client *redis.Client) *RedisCacheBackend {
+	return &RedisCacheBackend{client: client}
+}
+
+func (b *RedisCacheBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := b.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (b *RedisCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.client.Set(ctx, key, value, ttl).Err()
+}
+
+// SetMany 使用Pipeline减少网络往返
+func (b *RedisCacheBackend) SetMany(ctx context.Context, entries map[string]cacheValue) error {
+	pipe := b.client.Pipeline()
+	for key, entry := range entries {
+		pipe.Set(ctx, key, entry.data, entry.ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *RedisCacheBackend) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	pipe := b.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, key)
+	}
+
+	// Missing keys fail their own command with redis.Nil, which Exec reports too
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	values := make(map[string][]byte)
+	for i, cmd := range cmds {
+		if data, err := cmd.Bytes(); err == nil {
+			values[keys[i]] = data
+		}
+	}
+	return values, nil
+}
+
+func (b *RedisCacheBackend) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return b.client.Del(ctx, keys...).Err()
+}
+
+// TieredCacheBackend keeps a short-lived in-process copy of Redis entries, saving a round trip on
+// every authenticated request. Writes and deletes go to both tiers.
+type TieredCacheBackend struct {
+	local  *MemoryCacheBackend
+	remote *RedisCacheBackend
+}
+
+func NewTieredCacheBackend(local *MemoryCacheBackend, remote *RedisCacheBackend) *TieredCacheBackend {
+	return &TieredCacheBackend{local: local, remote: remote}
+}
+
+func (b *TieredCacheBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if data, found, _ := b.local.Get(ctx, key); found {
+		return data, true, nil
+	}
+
+	data, found, err := b.remote.Get(ctx, key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	b.local.Set(ctx, key, data, localCacheTTL)
+	return data, true, nil
+}
+
+func (b *TieredCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := b.remote.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return b.local.Set(ctx, key, value, localTTL(ttl))
+}
+
+func (b *TieredCacheBackend) SetMany(ctx context.Context, entries map[string]cacheValue) error {
+	if err := b.remote.SetMany(ctx, entries); err != nil {
+		return err
+	}
+
+	local := make(map[string]cacheValue, len(entries))
+	for key, entry := range entries {
+		local[key] = cacheValue{data: entry.data, ttl: localTTL(entry.ttl)}
+	}
+	return b.local.SetMany(ctx, local)
+}
+
+func (b *TieredCacheBackend) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values, _ := b.local.GetMany(ctx, keys)
+
+	var missing []string
+	for _, key := range keys {
+		if _, ok := values[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	remote, err := b.remote.GetMany(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for key, data := range remote {
+		values[key] = data
+		b.local.Set(ctx, key, data, localCacheTTL)
+	}
+	return values, nil
+}
+
+func (b *TieredCacheBackend) Delete(ctx context.Context, keys ...string) error {
+	b.local.Delete(ctx, keys...)
+	return b.remote.Delete(ctx, keys...)
+}
+
+// DeleteLocal drops the in-process copies only, for invalidations already applied to Redis by
+// another instance
+func (b *TieredCacheBackend) DeleteLocal(keys ...string) {
+	b.local.Delete(context.Background(), keys...)
+}
+
+func localTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > localCacheTTL {
+		return localCacheTTL
+	}
+	return ttl
+}
+
+// MemoryCacheBackend is a bounded in-process LRU cache with per-entry TTLs. Values are stored as
+// serialized copies, so callers never share the cached structs.
+type MemoryCacheBackend struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List // front is most recently used
+}
+
+type memoryCacheEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time // zero never expires
+}
+
+func NewMemoryCacheBackend(maxEntries int) *MemoryCacheBackend {
+	if maxEntries <= 0 {
+		maxEntries = defaultMemoryCacheEntries
+	}
+	return &MemoryCacheBackend{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (b *MemoryCacheBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, ok := b.get(key, time.Now())
+	return data, ok, nil
+}
+
+func (b *MemoryCacheBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.set(key, value, ttl, time.Now())
+	return nil
+}
+
+func (b *MemoryCacheBackend) SetMany(ctx context.Context, entries map[string]cacheValue) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range entries {
+		b.set(key, entry.data, entry.ttl, now)
+	}
+	return nil
+}
+
+func (b *MemoryCacheBackend) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		if data, ok := b.get(key, now); ok {
+			values[key] = data
+		}
+	}
+	return values, nil
+}
+
+func (b *MemoryCacheBackend) Delete(ctx context.Context, keys ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := b.entries[key]; ok {
+			b.remove(element)
+		}
+	}
+	return nil
+}
+
+// get returns a live entry and marks it recently used, dropping it when it has expired
+func (b *MemoryCacheBackend) get(key string, now time.Time) ([]byte, bool) {
+	element, ok := b.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*memoryCacheEntry)
+	if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+		b.remove(element)
+		return nil, false
+	}
+
+	b.lru.MoveToFront(element)
+	return entry.data, true
+}
+
+// set stores an entry, evicting the least recently used ones when the cache is full. Expired
+// entries are never touched again, so they drift to the back and go first.
+func (b *MemoryCacheBackend) set(key string, value []byte, ttl time.Duration, now time.Time) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+
+	if element, ok := b.entries[key]; ok {
+		entry := element.Value.(*memoryCacheEntry)
+		entry.data = value
+		entry.expiresAt = expiresAt
+		b.lru.MoveToFront(element)
+		return
+	}
+
+	for b.lru.Len() >= b.maxEntries {
+		b.remove(b.lru.Back())
+	}
+
+	b.entries[key] = b.lru.PushFront(&memoryCacheEntry{key: key, data: value, expiresAt: expiresAt})
+}
+
+func (b *MemoryCacheBackend) remove(element *list.Element) {
+	b.lru.Remove(element)
+	delete(b.entries, element.Value.(*memoryCacheEntry).key)
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"llm-inferra/internal/models"
+)
+
+// Circuit breaker defaults, used when a provider row leaves the fields unset
+const (
+	defaultBreakerConsecutiveFailures = 5
+	defaultBreakerErrorRatePercent    = 50
+	defaultBreakerMinRequests         = 20
+	defaultBreakerOpenDuration        = 30 * time.Second
+
+	// Error rates are measured over fixed windows of this length
+	breakerWindow = time.Minute
+)
+
+// breakerSettings is the circuit breaker configuration of a provider
+type breakerSettings struct {
+	consecutiveFailures int
+	errorRatePercent    int
+	minRequests         int
+	openDuration        time.Duration
+}
+
+func newBreakerSettings(provider *models.Provider) breakerSettings {
+	settings := breakerSettings{
+		consecutiveFailures: defaultBreakerConsecutiveFailures,
+		errorRatePercent:    defaultBreakerErrorRatePercent,
+		minRequests:         defaultBreakerMinRequests,
+		openDuration:        defaultBreakerOpenDuration,
+	}
+
+	if provider.BreakerConsecutiveFailures > 0 {
+		settings.consecutiveFailures = provider.BreakerConsecutiveFailures
+	}
+	if provider.BreakerErrorRatePercent > 0 {
+		settings.errorRatePercent = provider.BreakerErrorRatePercent
+	}
+	if provider.BreakerMinRequests > 0 {
+		settings.minRequests = provider.BreakerMinRequests
+	}
+	if provider.BreakerOpenSeconds > 0 {
+		settings.openDuration = time.Duration(provider.BreakerOpenSeconds) * time.Second
+	}
+
+	return settings
+}
+
+// circuitBreaker tracks failures of one provider or model.
+// closed: calls pass, failures are counted
+// open: calls fail fast until openDuration has passed
+// half_open: a single probe call is let through, its result closes or re-opens the breaker
+type circuitBreaker struct {
+	mu sync.Mutex
+
+	scope string
+	id    uint
+	name  string
+
+	state               models.CircuitState
+	consecutiveFailures int
+	windowStart         time.Time
+	requests            int
+	failures            int
+	openedAt            time.Time
+	probeInFlight       bool
+}
+
+// allow reports whether a call may go through, moving an expired open breaker to half-open
+func (b *circuitBreaker) allow(settings breakerSettings) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case models.CircuitOpen:
+		if time.Since(b.openedAt) < settings.openDuration {
+			return false
+		}
+		b.state = models.CircuitHalfOpen
+		b.probeInFlight = true
+		return true
+	case models.CircuitHalfOpen:
+		if b.probeInFlight {
+			return false
+		}
+		b.probeInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of a call
+func (b *circuitBreaker) record(settings breakerSettings, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.windowStart) >= breakerWindow {
+		b.windowStart = now
+		b.requests = 0
+		b.failures = 0
+	}
+	b.requests++
+
+	if !failed {
+		b.consecutiveFailures = 0
+		if b.state == models.CircuitHalfOpen {
+			// Probe succeeded, start over with a clean window
+			b.state = models.CircuitClosed
+			b.probeInFlight = false
+			b.windowStart = now
+			b.requests = 0
+			b.failures = 0
+		}
+		return
+	}
+
+	b.failures++
+	b.consecutiveFailures++
+
+	switch {
+	case b.state == models.CircuitHalfOpen:
+		b.open(now)
+	case b.consecutiveFailures >= settings.consecutiveFailures:
+		b.open(now)
+	case b.requests >= settings.minRequests && b.failures*100 >= b.requests*settings.errorRatePercent:
+		b.open(now)
+	}
+}
+
+func (b *circuitBreaker) open(now time.Time) {
+	b.state = models.CircuitOpen
+	b.openedAt = now
+	b.probeInFlight = false
+}
+
+func (b *circuitBreaker) status() models.CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := models.CircuitBreakerStatus{
+		Scope:               b.scope,
+		ID:                  b.id,
+		Name:                b.name,
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+		Requests:            b.requests,
+		Failures:            b.failures,
+	}
+	if b.state != models.CircuitClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// CircuitBreakerRegistry holds a circuit breaker per provider and per model
+type CircuitBreakerRegistry struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func NewCircuitBreakerRegistry() *CircuitBreakerRegistry {
+	return &CircuitBreakerRegistry{
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+func (r *CircuitBreakerRegistry) breaker(scope string, id uint, name string) *circuitBreaker {
+	key := fmt.Sprintf("%s:%d", scope, id)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[key]
+	if !ok {
+		b = &circuitBreaker{scope: scope, id: id, state: models.CircuitClosed, windowStart: time.Now()}
+		r.breakers[key] = b
+	}
+	b.name = name
+	return b
+}
+
+// Allow checks the provider and model breakers before an upstream call. Every allowed call
+// must be followed by Record.
+func (r *CircuitBreakerRegistry) Allow(provider *models.Provider, model *models.LLMModel) error {
+	settings := newBreakerSettings(provider)
+
+	if !r.breaker("provider", provider.ID, provider.Name).allow(settings) {
+		return fmt.Errorf("provider %s is temporarily unavailable (circuit open)", provider.Name)
+	}
+	if !r.breaker("model", model.ID, model.ModelID).allow(settings) {
+		// The provider breaker may have handed out its half-open probe, give it back unused
+		r.release("provider", provider.ID)
+		return fmt.Errorf("model %s is temporarily unavailable (circuit open)", model.ModelID)
+	}
+	return nil
+}
+
+// Record reports the outcome of an upstream call. Only failures that indicate an unhealthy
+// upstream (see isBreakerFailure) count against the breakers.
+func (r *CircuitBreakerRegistry) Record(provider *models.Provider, model *models.LLMModel, err error) {
+	settings := newBreakerSettings(provider)
+	failed := isBreakerFailure(err)
+
+	r.breaker("provider", provider.ID, provider.Name).record(settings, failed)
+	r.breaker("model", model.ID, model.ModelID).record(settings, failed)
+}
+
+// isBreakerFailure reports whether err counts as a failure for the breakers. A 429 is still worth
+// a fallback, but it only says our own quota is used up: the provider answered, and opening the
+// breaker would cut off every other key and credential that still has quota left.
+func isBreakerFailure(err error) bool {
+	if upstreamStatus(err) == http.StatusTooManyRequests {
+		return false
+	}
+	return isFallbackError(err)
+}
+
+// release frees a half-open probe slot that was not used
+func (r *CircuitBreakerRegistry) release(scope string, id uint) {
+	r.mu.Lock()
+	b, ok := r.breakers[fmt.Sprintf("%s:%d", scope, id)]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	b.mu.Lock()
+	b.probeInFlight = false
+	b.mu.Unlock()
+}
+
+// TripProvider forces a provider's breaker open, e.g. when health probes show it is down
+func (r *CircuitBreakerRegistry) TripProvider(provider *models.Provider) {
+	b := r.breaker("provider", provider.ID, provider.Name)
+
+	b.mu.Lock()
+	b.open(time.Now())
+	b.mu.Unlock()
+}
+
+// ResetProvider closes a provider's breaker, e.g. when health probes show it has recovered
+func (r *CircuitBreakerRegistry) ResetProvider(provider *models.Provider) {
+	b := r.breaker("provider", provider.ID, provider.Name)
+
+	b.mu.Lock()
+	b.state = models.CircuitClosed
+	b.consecutiveFailures = 0
+	b.probeInFlight = false
+	b.windowStart = time.Now()
+	b.requests = 0
+	b.failures = 0
+	b.mu.Unlock()
+}
+
+// ProviderState returns the breaker state of a provider
+func (r *CircuitBreakerRegistry) ProviderState(providerID uint) models.CircuitState {
+	r.mu.Lock()
+	b, ok := r.breakers[fmt.Sprintf("provider:%d", providerID)]
+	r.mu.Unlock()
+	if !ok {
+		return models.CircuitClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Snapshot returns the state of every breaker, providers first
+func (r *CircuitBreakerRegistry) Snapshot() []models.CircuitBreakerStatus {
+	r.mu.Lock()
+	breakers := make([]*circuitBreaker, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	r.mu.Unlock()
+
+	statuses := make([]models.CircuitBreakerStatus, 0, len(breakers))
+	for _, b := range breakers {
+		statuses = append(statuses, b.status())
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Scope != statuses[j].Scope {
+			return statuses[i].Scope == "provider"
+		}
+		return statuses[i].ID < statuses[j].ID
+	})
+	return statuses
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+
+	"llm-inferra/internal/models"
+)
+
+func TestCircuitBreakerIgnoresRateLimits(t *testing.T) {
+	registry := NewCircuitBreakerRegistry()
+	provider := &models.Provider{ID: 1, Name: "openai", BreakerConsecutiveFailures: 2}
+	model := &models.LLMModel{ID: 1, ModelID: "gpt-4o"}
+
+	rateLimited := &UpstreamError{StatusCode: http.StatusTooManyRequests}
+	for i := 0; i < 5; i++ {
+		if err := registry.Allow(provider, model); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		registry.Record(provider, model, rateLimited)
+	}
+	if state := registry.ProviderState(provider.ID); state != models.CircuitClosed {
+		t.Fatalf("state after 429s = %s, want closed", state)
+	}
+
+	unavailable := &UpstreamError{StatusCode: http.StatusServiceUnavailable}
+	for i := 0; i < 2; i++ {
+		registry.Allow(provider, model)
+		registry.Record(provider, model, unavailable)
+	}
+	if state := registry.ProviderState(provider.ID); state != models.CircuitOpen {
+		t.Fatalf("state after 503s = %s, want open", state)
+	}
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"llm-inferra/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// How long a credential is skipped after a 429 when the provider sent no Retry-After
+	defaultCredentialCooldown = 30 * time.Second
+	// Credential lists are reloaded at least this often, and on every provider change
+	credentialCacheTTL = time.Minute
+)
+
+// upstreamKey returns the key to send upstream: the credential picked for the request, otherwise
+// the client's API key. Virtual keys are never sent upstream.
+func upstreamKey(ctx *models.LLMRequestContext) string {
+	if ctx.UpstreamKey != "" {
+		return ctx.UpstreamKey
+	}
+	if ctx.APIKey.Virtual {
+		return ""
+	}
+	return ctx.APIKey.KeyValue
+}
+
+type cachedCredentials struct {
+	credentials []models.ProviderCredential
+	loadedAt    time.Time
+}
+
+// CredentialPool spreads upstream calls over a provider's pool of credentials using the
+// provider's CredentialStrategy, and records per-credential usage
+type CredentialPool struct {
+	db *gorm.DB
+
+	mu            sync.Mutex
+	cache         map[uint]*cachedCredentials
+	inFlight      map[uint]int
+	cooldownUntil map[uint]time.Time
+	currentWeight map[uint]int // smooth weighted round-robin state
+}
+
+func NewCredentialPool(db *gorm.DB) *CredentialPool {
+	return &CredentialPool{
+		db:            db,
+		cache:         make(map[uint]*cachedCredentials),
+		inFlight:      make(map[uint]int),
+		cooldownUntil: make(map[uint]time.Time),
+		currentWeight: make(map[uint]int),
+	}
+}
+
+// credentialLease is a credential handed out for one upstream call
+type credentialLease struct {
+	pool       *CredentialPool
+	credential models.ProviderCredential
+}
+
+// Acquire picks a credential for a call to the provider and marks it in flight.
+// It returns nil when the provider has no active credentials in its pool.
+func (p *CredentialPool) Acquire(provider *models.Provider) (*credentialLease, error) {
+	credentials, err := p.credentials(provider.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var selected *models.ProviderCredential
+	switch provider.CredentialStrategy {
+	case models.CredentialLeastInFlight:
+		selected = p.leastInFlight(credentials)
+	case models.CredentialSkipRateLimited:
+		now := time.Now()
+		available := make([]models.ProviderCredential, 0, len(credentials))
+		for _, credential := range credentials {
+			if !now.Before(p.cooldownUntil[credential.ID]) {
+				available = append(available, credential)
+			}
+		}
+		if len(available) == 0 {
+			// Every key is rate limited, use the one that recovers first
+			selected = p.soonestAvailable(credentials)
+		} else {
+			selected = p.weightedRoundRobin(available)
+		}
+	default:
+		selected = p.weightedRoundRobin(credentials)
+	}
+
+	p.inFlight[selected.ID]++
+	return &credentialLease{pool: p, credential: *selected}, nil
+}
+
+// AcquireFor picks the credential for a request: the one its client key is bound to, otherwise
+// one from the provider's pool. Virtual keys can't be sent upstream, so they fail without either.
+func (p *CredentialPool) AcquireFor(ctx *models.LLMRequestContext) (*credentialLease, error) {
+	if ctx.APIKey.UpstreamCredentialID != nil && ctx.APIKey.ProviderID == ctx.Provider.ID {
+		return p.acquireByID(ctx.Provider.ID, *ctx.APIKey.UpstreamCredentialID)
+	}
+
+	lease, err := p.Acquire(ctx.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil && ctx.APIKey.Virtual {
+		return nil, fmt.Errorf("no upstream credential configured for provider %s", ctx.Provider.Name)
+	}
+	return lease, nil
+}
+
+// acquireByID leases a specific active credential of the provider
+func (p *CredentialPool) acquireByID(providerID, credentialID uint) (*credentialLease, error) {
+	credentials, err := p.credentials(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, credential := range credentials {
+		if credential.ID == credentialID {
+			p.inFlight[credential.ID]++
+			return &credentialLease{pool: p, credential: credential}, nil
+		}
+	}
+	return nil, fmt.Errorf("upstream credential %d is not active", credentialID)
+}
+
+// weightedRoundRobin implements smooth weighted round-robin: every credential gains its weight,
+// the one with the highest current weight is picked and pays back the total weight
+func (p *CredentialPool) weightedRoundRobin(credentials []models.ProviderCredential) *models.ProviderCredential {
+	total := 0
+	var selected *models.ProviderCredential
+	for i := range credentials {
+		weight := credentialWeight(&credentials[i])
+		total += weight
+		p.currentWeight[credentials[i].ID] += weight
+		if selected == nil || p.currentWeight[credentials[i].ID] > p.currentWeight[selected.ID] {
+			selected = &credentials[i]
+		}
+	}
+	p.currentWeight[selected.ID] -= total
+	return selected
+}
+
+// leastInFlight picks the credential with the fewest in-flight calls relative to its weight
+func (p *CredentialPool) leastInFlight(credentials []models.ProviderCredential) *models.ProviderCredential {
+	var selected *models.ProviderCredential
+	var selectedLoad float64
+	for i := range credentials {
+		load := float64(p.inFlight[credentials[i].ID]) / float64(credentialWeight(&credentials[i]))
+		if selected == nil || load < selectedLoad {
+			selected = &credentials[i]
+			selectedLoad = load
+		}
+	}
+	return selected
+}
+
+// soonestAvailable picks the credential whose rate limit cooldown ends first
+func (p *CredentialPool) soonestAvailable(credentials []models.ProviderCredential) *models.ProviderCredential {
+	selected := &credentials[0]
+	for i := range credentials[1:] {
+		if p.cooldownUntil[credentials[i+1].ID].Before(p.cooldownUntil[selected.ID]) {
+			selected = &credentials[i+1]
+		}
+	}
+	return selected
+}
+
+func credentialWeight(credential *models.ProviderCredential) int {
+	if credential.Weight <= 0 {
+		return 1
+	}
+	return credential.Weight
+}
+
+// credentials returns the active credentials of a provider, cached for credentialCacheTTL
+func (p *CredentialPool) credentials(providerID uint) ([]models.ProviderCredential, error) {
+	p.mu.Lock()
+	cached, ok := p.cache[providerID]
+	p.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < credentialCacheTTL {
+		return cached.credentials, nil
+	}
+
+	var credentials []models.ProviderCredential
+	if err := p.db.Where("provider_id = ? AND status = ?", providerID, models.CredentialStatusActive).
+		Order("id ASC").
+		Find(&credentials).Error; err != nil {
+		return nil, fmt.Errorf("failed to load provider credentials: %w", err)
+	}
+
+	p.mu.Lock()
+	p.cache[providerID] = &cachedCredentials{credentials: credentials, loadedAt: time.Now()}
+	p.mu.Unlock()
+
+	return credentials, nil
+}
+
+// ProviderChanged drops the cached credentials so pool edits take effect immediately
+func (p *CredentialPool) ProviderChanged(provider *models.Provider) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cache, provider.ID)
+}
+
+// ProviderDeleted drops the cached credentials of a deleted provider
+func (p *CredentialPool) ProviderDeleted(providerID uint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cache, providerID)
+}
+
+// apply points the request context at the leased credential
+func (l *credentialLease) apply(ctx *models.LLMRequestContext) {
+	id := l.credential.ID
+	ctx.CredentialID = &id
+	ctx.UpstreamKey = l.credential.KeyValue
+}
+
+// record stores the outcome of the call, putting the credential on cooldown after a 429
+func (l *credentialLease) record(err error) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"total_requests": gorm.Expr("total_requests + 1"),
+		"last_used_at":   now,
+	}
+
+	if err != nil && isFallbackError(err) {
+		updates["failed_requests"] = gorm.Expr("failed_requests + 1")
+	}
+
+	var upstreamErr *UpstreamError
+	if err != nil && upstreamStatus(err) == http.StatusTooManyRequests {
+		cooldown := defaultCredentialCooldown
+		if errors.As(err, &upstreamErr) && upstreamErr.RetryAfter > 0 {
+			cooldown = upstreamErr.RetryAfter
+		}
+
+		l.pool.mu.Lock()
+		l.pool.cooldownUntil[l.credential.ID] = now.Add(cooldown)
+		l.pool.mu.Unlock()
+
+		updates["rate_limited_requests"] = gorm.Expr("rate_limited_requests + 1")
+		updates["last_rate_limited_at"] = now
+	}
+
+	if dbErr := l.pool.db.Model(&models.ProviderCredential{}).Where("id = ?", l.credential.ID).Updates(updates).Error; dbErr != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to record credential usage: %v\n", dbErr)
+	}
+}
+
+// release marks the call as no longer in flight
+func (l *credentialLease) release() {
+	l.pool.Release(&l.credential.ID)
+}
+
+// Release marks a call on the credential as finished. Streams hold their credential until the
+// stream ends, so they release it by ID from the stream goroutine.
+func (p *CredentialPool) Release(credentialID *uint) {
+	if credentialID == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.inFlight[*credentialID] > 0 {
+		p.inFlight[*credentialID]--
+	}
+}
+
+// RecordUsage adds the tokens and cost of a completed request to its credential
+func (p *CredentialPool) RecordUsage(credentialID *uint, usage *models.ChatCompletionUsage, cost float64) {
+	if credentialID == nil || usage == nil {
+		return
+	}
+
+	updates := map[string]interface{}{
+		"total_tokens": gorm.Expr("total_tokens + ?", usage.TotalTokens),
+		"total_cost":   gorm.Expr("total_cost + ?", cost),
+	}
+	if err := p.db.Model(&models.ProviderCredential{}).Where("id = ?", *credentialID).Updates(updates).Error; err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to record credential usage: %v\n", err)
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// CustomProvider serves providers of type "custom" by talking to any OpenAI-compatible
+// endpoint (vLLM, llama.cpp server, Ollama, LM Studio, ...) at the provider's configured BaseURL.
+// Request/response handling is identical to OpenAI, only the target and timeouts differ.
+type CustomProvider struct {
+	*OpenAIProvider
+}
+
+func NewCustomProvider(baseURL string) (*CustomProvider, error) {
+	if err := validateCustomBaseURL(baseURL); err != nil {
+		return nil, err
+	}
+
+	provider := NewOpenAIProvider(baseURL)
+	provider.httpClient = newUpstreamHTTPClient(customResponseHeaderTimeout)
+
+	return &CustomProvider{OpenAIProvider: provider}, nil
+}
+
+// validateCustomBaseURL ensures a custom provider points at an absolute http(s) URL
+func validateCustomBaseURL(baseURL string) error {
+	if baseURL == "" {
+		return fmt.Errorf("base_url is required for custom providers")
+	}
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("invalid base_url for custom provider: %s", baseURL)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"llm-inferra/internal/models"
+)
+
+// Embeddings creates embeddings through the key's provider, logging usage and cost like chat completions
+func (s *LLMService) Embeddings(ctx *models.LLMRequestContext, req *models.EmbeddingRequest, clientIP, userAgent string) (*models.EmbeddingResponse, error) {
+	// Update context with client info
+	ctx.ClientIP = clientIP
+	ctx.UserAgent = userAgent
+
+	if err := validateEmbeddingRequest(req); err != nil {
+		return nil, fmt.Errorf("request validation failed: %w", err)
+	}
+
+	// Get model information
+	model, err := s.GetModelByName(ctx.Provider.ID, req.Model)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Model = model
+
+	if err := checkAPIKeyScope(ctx.APIKey, models.APIKeyScopeEmbeddings, model.ModelID); err != nil {
+		return nil, err
+	}
+
+	if !model.SupportsEmbeddings {
+		return nil, fmt.Errorf("request validation failed: model %s does not support embeddings", model.ModelID)
+	}
+
+	response, err := s.embed(ctx, model, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.EncodingFormat == models.EmbeddingEncodingBase64 {
+		for i := range response.Data {
+			if vector, ok := response.Data[i].Embedding.([]float64); ok {
+				response.Data[i].Embedding = encodeEmbeddingBase64(vector)
+			}
+		}
+	}
+
+	return response, nil
+}
+
+// embed makes an embedding call for ctx's key with the budget and rate limit checks, request log
+// and upstream credential handling every request of the key gets
+func (s *LLMService) embed(ctx *models.LLMRequestContext, model *models.LLMModel, req *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	// Get provider implementation
+	provider, err := s.providers.Get(ctx.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	embedder, ok := provider.(models.EmbeddingProvider)
+	if !ok {
+		return nil, fmt.Errorf("request validation failed: provider %s does not support embeddings", ctx.Provider.Name)
+	}
+
+	// Check the cost budgets, holding the estimated cost until the request finishes
+	estimate := float64(estimateEmbeddingTokens(req)) * model.InputCostPer1K / 1000.0
+	reservation, err := s.budgets.Reserve(ctx, estimate)
+	if err != nil {
+		return nil, err
+	}
+	var cost float64
+	defer func() { reservation.Settle(cost) }()
+
+	// Check the rate limits on the estimated input tokens, which embeddings use without output
+	rateLease, err := s.rateLimits.Admit(ctx, estimateEmbeddingTokens(req))
+	if err != nil {
+		return nil, err
+	}
+	var tokens int
+	defer func() { rateLease.Reconcile(tokens) }()
+
+	// Create request log (now that ctx.Model is set)
+	requestLog, err := s.createRequestLog(ctx, req.Model, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request log: %w", err)
+	}
+
+	if err := s.breakers.Allow(ctx.Provider, model); err != nil {
+		s.updateRequestLogError(requestLog.ID, err, 0)
+		return nil, err
+	}
+
+	// Pick an upstream credential from the provider's pool, if it has one
+	lease, err := s.credentials.AcquireFor(ctx)
+	if err != nil {
+		s.breakers.release("provider", ctx.Provider.ID)
+		s.breakers.release("model", model.ID)
+		s.updateRequestLogError(requestLog.ID, err, 0)
+		return nil, err
+	}
+	if lease != nil {
+		lease.apply(ctx)
+		defer lease.release()
+		s.updateRequestLogCredential(requestLog.ID, lease.credential.ID)
+	}
+
+	// Make the API call
+	startTime := time.Now()
+	response, err := embedder.Embeddings(ctx, req)
+	latency := time.Since(startTime)
+	s.breakers.Record(ctx.Provider, model, err)
+	if lease != nil {
+		lease.record(err)
+	}
+
+	// Update request log with response
+	if err != nil {
+		s.updateRequestLogError(requestLog.ID, err, int(latency.Milliseconds()))
+		return nil, err
+	}
+	if response.Model == "" {
+		response.Model = req.Model
+	}
+
+	// Embeddings only consume input tokens
+	usage := &models.ChatCompletionUsage{
+		InputTokens:  response.Usage.PromptTokens,
+		TotalTokens:  response.Usage.PromptTokens,
+		PromptTokens: response.Usage.PromptTokens,
+	}
+	inputCost, outputCost, totalCost := provider.CalculateCost(usage, model)
+
+	// 不记录向量本身，避免请求日志体积过大
+	logged := map[string]interface{}{
+		"object": response.Object,
+		"model":  response.Model,
+		"count":  len(response.Data),
+		"usage":  response.Usage,
+	}
+	err = s.updateRequestLogSuccess(requestLog.ID, logged, usage, inputCost, outputCost, totalCost, int(latency.Milliseconds()))
+	if err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to update request log: %v\n", err)
+	}
+	s.credentials.RecordUsage(ctx.CredentialID, usage, totalCost)
+	cost = totalCost
+	tokens = usage.TotalTokens
+
+	return response, nil
+}
+
+// validateEmbeddingRequest checks the input and optional parameters
+func validateEmbeddingRequest(req *models.EmbeddingRequest) error {
+	if req.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+
+	if len(req.Input) == 0 {
+		return fmt.Errorf("input cannot be empty")
+	}
+
+	for i, text := range req.Input {
+		if text == "" {
+			return fmt.Errorf("input %d cannot be empty", i)
+		}
+	}
+
+	if req.Dimensions != nil && *req.Dimensions <= 0 {
+		return fmt.Errorf("dimensions must be greater than 0")
+	}
+
+	switch req.EncodingFormat {
+	case "", models.EmbeddingEncodingFloat, models.EmbeddingEncodingBase64:
+	default:
+		return fmt.Errorf("unsupported encoding_format '%s'", req.EncodingFormat)
+	}
+
+	return nil
+}
+
+// encodeEmbeddingBase64 encodes a vector as base64 of little-endian float32 values, as OpenAI does
+func encodeEmbeddingBase64(vector []float64) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"llm-inferra/internal/models"
+)
+
+// fallbackTarget is a model to try for a request, together with the context (provider and
+// upstream credentials) to call it with
+type fallbackTarget struct {
+	ctx   *models.LLMRequestContext
+	model *models.LLMModel
+}
+
+// fallbackTargets returns the primary model followed by its configured fallback chain.
+// A fallback on another provider is called with the same user's active, unexpired API key for that
+// provider; fallbacks the user has no key for are left out.
+func (s *LLMService) fallbackTargets(ctx *models.LLMRequestContext, model *models.LLMModel) []fallbackTarget {
+	targets := []fallbackTarget{{ctx: ctx, model: model}}
+
+	var fallbacks []models.ModelFallback
+	err := s.db.Preload("FallbackModel.Provider").
+		Where("model_id = ?", model.ID).
+		Order("priority ASC").
+		Find(&fallbacks).Error
+	if err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to load fallbacks for model %s: %v\n", model.ModelID, err)
+		return targets
+	}
+
+	for _, fallback := range fallbacks {
+		fallbackModel := fallback.FallbackModel
+		if fallbackModel.ID == 0 || fallbackModel.Status != models.ModelStatusActive {
+			continue
+		}
+		if fallbackModel.Provider.Status != models.ProviderStatusActive {
+			continue
+		}
+
+		target := *ctx
+		target.Provider = &fallbackModel.Provider
+		target.Model = &fallbackModel
+
+		if fallbackModel.ProviderID != ctx.Provider.ID {
+			apiKey, err := s.fallbackAPIKey(ctx.UserID, fallbackModel.ProviderID)
+			if err != nil {
+				continue
+			}
+			target.APIKey = apiKey
+		}
+
+		targets = append(targets, fallbackTarget{ctx: &target, model: &fallbackModel})
+	}
+
+	return targets
+}
+
+// fallbackAPIKey picks the user's key for a fallback provider. Expired keys are left out; virtual
+// keys come first since their upstream credentials are managed by the gateway, then the oldest key,
+// so the same key is picked on every request.
+func (s *LLMService) fallbackAPIKey(userID, providerID uint) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	err := s.db.Where("user_id = ? AND provider_id = ? AND status = ?", userID, providerID, models.APIKeyStatusActive).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("virtual DESC, id ASC").
+		First(&apiKey).Error
+	if err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+// callWithFallback calls the primary model and, on retryable errors (see isFallbackError),
+// each fallback in turn. Every attempt is recorded against the request log. On success ctx
+// is updated to the provider and model that served the request.
+func (s *LLMService) callWithFallback(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest, logID uint,
+	call func(provider models.LLMProvider, ctx *models.LLMRequestContext, req *models.ChatCompletionRequest) error) (models.LLMProvider, error) {
+	var lastErr error
+
+	for i, target := range s.fallbackTargets(ctx, ctx.Model) {
+		attempt := i + 1
+		attemptReq := req
+
+		if i > 0 {
+			// The fallback model has to be allowed for the key the client authenticated with, and
+			// support everything the request uses. The fallback provider's key only supplies the
+			// upstream credential, its scopes don't widen what the client may call.
+			if err := checkAPIKeyScope(ctx.APIKey, models.APIKeyScopeChat, target.model.ModelID); err != nil {
+				s.recordAttempt(logID, attempt, target, "skipped", err, 0)
+				continue
+			}
+			if err := s.validateModelCapabilities(target.model, req); err != nil {
+				s.recordAttempt(logID, attempt, target, "skipped", err, 0)
+				continue
+			}
+			if req.Stream {
+				if err := validateStreamFormat(target.ctx.Provider, req); err != nil {
+					s.recordAttempt(logID, attempt, target, "skipped", err, 0)
+					continue
+				}
+			}
+
+			fallbackReq := *req
+			fallbackReq.Model = target.model.ModelID
+			attemptReq = &fallbackReq
+		}
+
+		provider, err := s.providers.Get(target.ctx.Provider)
+		if err != nil {
+			s.recordAttempt(logID, attempt, target, "skipped", err, 0)
+			lastErr = err
+			continue
+		}
+
+		// Fail fast on an open circuit and move on to the next fallback
+		if err := s.breakers.Allow(target.ctx.Provider, target.model); err != nil {
+			s.recordAttempt(logID, attempt, target, "skipped", err, 0)
+			lastErr = err
+			continue
+		}
+
+		// Pick the upstream credential: the key's own, or one from the provider's pool
+		lease, err := s.credentials.AcquireFor(target.ctx)
+		if err != nil {
+			s.breakers.release("provider", target.ctx.Provider.ID)
+			s.breakers.release("model", target.model.ID)
+			s.recordAttempt(logID, attempt, target, "skipped", err, 0)
+			lastErr = err
+			continue
+		}
+		if lease != nil {
+			lease.apply(target.ctx)
+		}
+
+		startTime := time.Now()
+		err = call(provider, target.ctx, attemptReq)
+		latency := time.Since(startTime)
+		s.breakers.Record(target.ctx.Provider, target.model, err)
+		if lease != nil {
+			lease.record(err)
+			// A started stream keeps its credential in flight until the stream ends
+			if err != nil || !req.Stream {
+				lease.release()
+			}
+		}
+
+		if err == nil {
+			s.recordAttempt(logID, attempt, target, "completed", nil, latency)
+			if i > 0 {
+				ctx.Provider = target.ctx.Provider
+				ctx.Model = target.model
+				s.updateRequestLogServedBy(logID, target.model)
+			}
+			ctx.CredentialID = target.ctx.CredentialID
+			ctx.UpstreamKey = target.ctx.UpstreamKey
+			if ctx.CredentialID != nil {
+				s.updateRequestLogCredential(logID, *ctx.CredentialID)
+			}
+			return provider, nil
+		}
+
+		s.recordAttempt(logID, attempt, target, "failed", err, latency)
+		lastErr = err
+		if !isFallbackError(err) {
+			break
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no available provider for model %s", req.Model)
+	}
+	return nil, lastErr
+}
+
+// recordAttempt stores one upstream attempt for a request
+func (s *LLMService) recordAttempt(logID uint, attempt int, target fallbackTarget, status string, err error, latency time.Duration) {
+	record := &models.LLMRequestAttempt{
+		RequestLogID: logID,
+		Attempt:      attempt,
+		ProviderID:   target.model.ProviderID,
+		ModelID:      target.model.ID,
+		ModelName:    target.model.ModelID,
+		CredentialID: target.ctx.CredentialID,
+		Status:       status,
+		LatencyMs:    latency.Milliseconds(),
+	}
+	if err != nil {
+		record.ErrorMessage = err.Error()
+		record.HTTPStatus = upstreamStatus(err)
+	} else {
+		record.HTTPStatus = 200
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to record request attempt: %v\n", err)
+	}
+}
+
+// updateRequestLogServedBy points the request log at the fallback model that served the request
+func (s *LLMService) updateRequestLogServedBy(logID uint, model *models.LLMModel) error {
+	updates := map[string]interface{}{
+		"provider_id": model.ProviderID,
+		"model_id":    model.ID,
+		"model_name":  model.ModelID,
+	}
+
+	return s.db.Model(&models.LLMRequestLog{}).Where("id = ?", logID).Updates(updates).Error
+}
+
+// updateRequestLogCredential records which pooled upstream credential served the request
+func (s *LLMService) updateRequestLogCredential(logID uint, credentialID uint) error {
+	return s.db.Model(&models.LLMRequestLog{}).Where("id = ?", logID).Update("credential_id", credentialID).Error
+}
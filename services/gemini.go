@@ -0,0 +1,652 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"llm-inferra/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type GeminiProvider struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+func NewGeminiProvider(baseURL string) *GeminiProvider {
+	return &GeminiProvider{
+		httpClient: newUpstreamHTTPClient(defaultResponseHeaderTimeout),
+		baseURL:    strings.TrimRight(baseURL, "/"),
+	}
+}
+
+func (gp *GeminiProvider) ValidateRequest(req *models.ChatCompletionRequest) error {
+	if req.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+
+	if len(req.Messages) == 0 {
+		return fmt.Errorf("messages are required")
+	}
+
+	// Validate message roles for Gemini, system messages are folded into systemInstruction
+	hasConversation := false
+	for i, msg := range req.Messages {
+		if msg.Role != "system" && msg.Role != "user" && msg.Role != "assistant" && msg.Role != "tool" {
+			return fmt.Errorf("message %d: role must be 'system', 'user', 'assistant' or 'tool' for Gemini", i)
+		}
+		if err := validateToolMessage(i, msg); err != nil {
+			return err
+		}
+		if msg.Role != "system" {
+			hasConversation = true
+		}
+	}
+
+	if !hasConversation {
+		return fmt.Errorf("at least one user message is required for Gemini")
+	}
+
+	return validateTools(req)
+}
+
+// TransformRequest converts the unified request into the Gemini generateContent format.
+// The assistant role is called "model" by Gemini, and sampling parameters live under generationConfig.
+func (gp *GeminiProvider) TransformRequest(req *models.ChatCompletionRequest) (interface{}, error) {
+	var contents []models.GeminiContent
+	var systemParts []models.GeminiPart
+
+	if req.System != "" {
+		systemParts = append(systemParts, models.GeminiPart{Text: req.System})
+	}
+
+	// Gemini function responses are matched by name, not by call ID
+	toolNames := make(map[string]string)
+	lastWasToolResult := false
+
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			systemParts = append(systemParts, models.GeminiPart{Text: msg.Content.String()})
+		case "assistant":
+			var parts []models.GeminiPart
+			if text := msg.Content.String(); text != "" {
+				parts = append(parts, models.GeminiPart{Text: text})
+			}
+			for _, call := range msg.ToolCalls {
+				toolNames[call.ID] = call.Function.Name
+				parts = append(parts, models.GeminiPart{FunctionCall: &models.GeminiFunctionCall{
+					Name: call.Function.Name,
+					Args: toolArguments(call.Function.Arguments),
+				}})
+			}
+			contents = append(contents, models.GeminiContent{Role: "model", Parts: parts})
+		case "tool":
+			name := msg.Name
+			if name == "" {
+				name = toolNames[msg.ToolCallID]
+			}
+			part := models.GeminiPart{FunctionResponse: &models.GeminiFunctionResponse{
+				Name:     name,
+				Response: geminiFunctionResponse(msg.Content.String()),
+			}}
+			// All responses to one model turn go into a single content entry
+			if lastWasToolResult {
+				last := &contents[len(contents)-1]
+				last.Parts = append(last.Parts, part)
+			} else {
+				contents = append(contents, models.GeminiContent{Role: "user", Parts: []models.GeminiPart{part}})
+			}
+			lastWasToolResult = true
+			continue
+		default:
+			parts, err := geminiParts(msg.Content)
+			if err != nil {
+				return nil, err
+			}
+			contents = append(contents, models.GeminiContent{Role: "user", Parts: parts})
+		}
+		lastWasToolResult = false
+	}
+
+	geminiReq := map[string]interface{}{
+		"contents": contents,
+	}
+
+	if tools := requestTools(req); len(tools) > 0 {
+		declarations := make([]map[string]interface{}, 0, len(tools))
+		for _, tool := range tools {
+			declaration := map[string]interface{}{
+				"name": tool.Function.Name,
+			}
+			if tool.Function.Description != "" {
+				declaration["description"] = tool.Function.Description
+			}
+			if len(tool.Function.Parameters) > 0 {
+				declaration["parameters"] = tool.Function.Parameters
+			}
+			declarations = append(declarations, declaration)
+		}
+		geminiReq["tools"] = []map[string]interface{}{
+			{"functionDeclarations": declarations},
+		}
+
+		callingConfig := map[string]interface{}{}
+		switch mode, name := requestToolChoice(req); mode {
+		case "required":
+			callingConfig["mode"] = "ANY"
+		case "function":
+			callingConfig["mode"] = "ANY"
+			callingConfig["allowedFunctionNames"] = []string{name}
+		case "none":
+			callingConfig["mode"] = "NONE"
+		default:
+			callingConfig["mode"] = "AUTO"
+		}
+		geminiReq["toolConfig"] = map[string]interface{}{
+			"functionCallingConfig": callingConfig,
+		}
+	}
+
+	if len(systemParts) > 0 {
+		geminiReq["systemInstruction"] = models.GeminiContent{Parts: systemParts}
+	}
+
+	generationConfig := map[string]interface{}{}
+
+	if req.MaxTokens != nil {
+		generationConfig["maxOutputTokens"] = *req.MaxTokens
+	}
+
+	if req.Temperature != nil {
+		generationConfig["temperature"] = *req.Temperature
+	}
+
+	if req.TopP != nil {
+		generationConfig["topP"] = *req.TopP
+	}
+
+	if req.TopK != nil {
+		generationConfig["topK"] = *req.TopK
+	}
+
+	if stop := stopSequences(req.Stop); len(stop) > 0 {
+		generationConfig["stopSequences"] = stop
+	}
+
+	if len(generationConfig) > 0 {
+		geminiReq["generationConfig"] = generationConfig
+	}
+
+	return geminiReq, nil
+}
+
+// TransformResponse converts a Gemini generateContent response into an OpenAI-compatible response.
+func (gp *GeminiProvider) TransformResponse(resp interface{}) (*models.ChatCompletionResponse, error) {
+	geminiResp, ok := resp.(*models.GeminiResponse)
+	if !ok {
+		return nil, fmt.Errorf("invalid response type")
+	}
+
+	id := geminiResp.ResponseID
+	if id == "" {
+		id = "chatcmpl-" + uuid.New().String()
+	}
+
+	usage := geminiResp.UsageMetadata
+	response := &models.ChatCompletionResponse{
+		ID:      id,
+		Model:   geminiResp.ModelVersion,
+		Role:    "assistant",
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Usage: models.ChatCompletionUsage{
+			InputTokens:      usage.PromptTokenCount,
+			OutputTokens:     usage.CandidatesTokenCount,
+			TotalTokens:      usage.PromptTokenCount + usage.CandidatesTokenCount,
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount,
+		},
+	}
+
+	for i, candidate := range geminiResp.Candidates {
+		text := geminiCandidateText(candidate)
+		toolCalls := geminiToolCalls(candidate, nil)
+
+		finishReason := geminiFinishReason(candidate.FinishReason)
+		if len(toolCalls) > 0 {
+			finishReason = "tool_calls"
+		}
+
+		response.Choices = append(response.Choices, models.ChatCompletionChoice{
+			Index: i,
+			Message: models.ChatMessage{
+				Role:      "assistant",
+				Content:   models.TextContent(text),
+				ToolCalls: toolCalls,
+			},
+			FinishReason: finishReason,
+		})
+
+		if i == 0 {
+			if text != "" {
+				response.Content = append(response.Content, models.ChatCompletionContent{Type: "text", Text: text})
+			}
+			for _, call := range toolCalls {
+				response.Content = append(response.Content, models.ChatCompletionContent{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Function.Name,
+					Input: json.RawMessage(call.Function.Arguments),
+				})
+			}
+		}
+	}
+
+	return response, nil
+}
+
+func (gp *GeminiProvider) ChatCompletion(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest) (*models.ChatCompletionResponse, error) {
+	// Validate request
+	if err := gp.ValidateRequest(req); err != nil {
+		return nil, fmt.Errorf("request validation failed: %w", err)
+	}
+
+	// Transform request for Gemini API
+	geminiReq, err := gp.TransformRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("request transformation failed: %w", err)
+	}
+
+	httpReq, err := gp.newHTTPRequest(ctx, req.Model, "generateContent", geminiReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the request
+	httpResp, err := gp.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	// Read response body
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Handle non-2xx status codes
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return nil, newUpstreamError(httpResp, respBody)
+	}
+
+	// Parse Gemini response
+	var geminiResp models.GeminiResponse
+	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Transform to standard response format
+	response, err := gp.TransformResponse(&geminiResp)
+	if err != nil {
+		return nil, fmt.Errorf("response transformation failed: %w", err)
+	}
+	if response.Model == "" {
+		response.Model = req.Model
+	}
+
+	return response, nil
+}
+
+func (gp *GeminiProvider) StreamChatCompletion(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest) (<-chan []byte, error) {
+	// Set streaming flag
+	req.Stream = true
+
+	// Validate request
+	if err := gp.ValidateRequest(req); err != nil {
+		return nil, fmt.Errorf("request validation failed: %w", err)
+	}
+
+	// Transform request for Gemini API
+	geminiReq, err := gp.TransformRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("request transformation failed: %w", err)
+	}
+
+	httpReq, err := gp.newHTTPRequest(ctx, req.Model, "streamGenerateContent", geminiReq)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Cache-Control", "no-cache")
+
+	// Make the request
+	httpResp, err := gp.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	// Handle non-2xx status codes
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		defer httpResp.Body.Close()
+		respBody, _ := io.ReadAll(httpResp.Body)
+		return nil, newUpstreamError(httpResp, respBody)
+	}
+
+	// Create channel for streaming response
+	streamChan := make(chan []byte, 100)
+
+	go func() {
+		defer close(streamChan)
+		defer httpResp.Body.Close()
+
+		chunkID := "chatcmpl-" + uuid.New().String()
+		created := time.Now().Unix()
+
+		// Gemini reports cumulative usage on every chunk, only the last one is billed
+		var lastUsage *models.GeminiUsageMetadata
+		// Tool calls streamed so far per candidate, parallel calls arrive in separate chunks
+		toolCallCounts := make(map[int]int)
+
+		scanner := bufio.NewScanner(httpResp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "" {
+				continue
+			}
+
+			var chunk models.GeminiResponse
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				continue
+			}
+
+			if chunk.UsageMetadata.PromptTokenCount > 0 || chunk.UsageMetadata.CandidatesTokenCount > 0 {
+				usage := chunk.UsageMetadata
+				lastUsage = &usage
+			}
+
+			if processedData := gp.transformStreamChunk(&chunk, chunkID, req.Model, created, toolCallCounts); processedData != nil {
+				streamChan <- processedData
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			// Send error as last message
+			errorMsg := fmt.Sprintf("data: {\"error\": \"%v\"}\n\n", err)
+			streamChan <- []byte(errorMsg)
+			return
+		}
+
+		if lastUsage != nil {
+			if includeUsage(req) {
+				streamChan <- usageChunk(chunkID, req.Model, created, lastUsage.PromptTokenCount, lastUsage.CandidatesTokenCount)
+			}
+			streamChan <- usageUpdateEvent(lastUsage.PromptTokenCount, lastUsage.CandidatesTokenCount)
+		}
+	}()
+
+	return streamChan, nil
+}
+
+// transformStreamChunk converts a Gemini stream chunk into an OpenAI chat.completion.chunk SSE event.
+// toolCallCounts holds the tool calls already streamed per candidate, so the calls of later
+// chunks get the next tool call indexes.
+func (gp *GeminiProvider) transformStreamChunk(chunk *models.GeminiResponse, id, model string, created int64, toolCallCounts map[int]int) []byte {
+	if len(chunk.Candidates) == 0 {
+		return nil
+	}
+
+	choices := make([]map[string]interface{}, 0, len(chunk.Candidates))
+	for i, candidate := range chunk.Candidates {
+		delta := map[string]interface{}{
+			"role":    "assistant",
+			"content": geminiCandidateText(candidate),
+		}
+
+		// Gemini streams each function call whole, so it is emitted as a single tool_calls delta
+		nextIndex := toolCallCounts[i]
+		toolCalls := geminiToolCalls(candidate, &nextIndex)
+		toolCallCounts[i] = nextIndex
+		if len(toolCalls) > 0 {
+			delta["tool_calls"] = toolCalls
+		}
+
+		choice := map[string]interface{}{
+			"index":         i,
+			"delta":         delta,
+			"finish_reason": nil,
+		}
+		// Further calls may follow in later chunks, the choice only finishes with Gemini's finish reason
+		if candidate.FinishReason != "" {
+			if toolCallCounts[i] > 0 {
+				choice["finish_reason"] = "tool_calls"
+			} else {
+				choice["finish_reason"] = geminiFinishReason(candidate.FinishReason)
+			}
+		}
+		choices = append(choices, choice)
+	}
+
+	return sseData(map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"model":   model,
+		"choices": choices,
+	})
+}
+
+// Embeddings calls batchEmbedContents with one request per input text.
+// Gemini does not report token usage for embeddings, so it is estimated from the input length.
+func (gp *GeminiProvider) Embeddings(ctx *models.LLMRequestContext, req *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	requests := make([]map[string]interface{}, 0, len(req.Input))
+	for _, text := range req.Input {
+		embedReq := map[string]interface{}{
+			"model":   "models/" + req.Model,
+			"content": models.GeminiContent{Parts: []models.GeminiPart{{Text: text}}},
+		}
+		if req.Dimensions != nil {
+			embedReq["outputDimensionality"] = *req.Dimensions
+		}
+		requests = append(requests, embedReq)
+	}
+
+	httpReq, err := gp.newHTTPRequest(ctx, req.Model, "batchEmbedContents", map[string]interface{}{"requests": requests})
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the request
+	httpResp, err := gp.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	// Read response body
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Handle non-2xx status codes
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return nil, newUpstreamError(httpResp, respBody)
+	}
+
+	// Parse Gemini response
+	var geminiResp struct {
+		Embeddings []struct {
+			Values []float64 `json:"values"`
+		} `json:"embeddings"`
+	}
+	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// 粗略估算：约4个字符一个token
+	promptTokens := 0
+	for _, text := range req.Input {
+		promptTokens += (len(text) + 3) / 4
+	}
+
+	response := &models.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]models.EmbeddingData, 0, len(geminiResp.Embeddings)),
+		Model:  req.Model,
+		Usage: models.EmbeddingUsage{
+			PromptTokens: promptTokens,
+			TotalTokens:  promptTokens,
+		},
+	}
+	for i, embedding := range geminiResp.Embeddings {
+		response.Data = append(response.Data, models.EmbeddingData{
+			Object:    "embedding",
+			Index:     i,
+			Embedding: embedding.Values,
+		})
+	}
+
+	return response, nil
+}
+
+// newHTTPRequest builds an authenticated POST to models/{model}:{method}
+func (gp *GeminiProvider) newHTTPRequest(ctx *models.LLMRequestContext, model, method string, body interface{}) (*http.Request, error) {
+	// Serialize request
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/models/%s:%s", gp.baseURL, model, method)
+	if method == "streamGenerateContent" {
+		url += "?alt=sse"
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	// Set headers
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", upstreamKey(ctx))
+
+	return httpReq, nil
+}
+
+// Helper function to calculate cost
+func (gp *GeminiProvider) CalculateCost(usage *models.ChatCompletionUsage, model *models.LLMModel) (inputCost, outputCost, totalCost float64) {
+	inputCost = float64(usage.InputTokens) * model.InputCostPer1K / 1000.0
+	outputCost = float64(usage.OutputTokens) * model.OutputCostPer1K / 1000.0
+	totalCost = inputCost + outputCost
+	return
+}
+
+// geminiCandidateText concatenates the text parts of a candidate
+func geminiCandidateText(candidate models.GeminiCandidate) string {
+	var sb strings.Builder
+	for _, part := range candidate.Content.Parts {
+		sb.WriteString(part.Text)
+	}
+	return sb.String()
+}
+
+// geminiToolCalls converts the functionCall parts of a candidate into OpenAI tool calls.
+// Gemini does not assign call IDs, so one is generated per call. Stream deltas carry an index:
+// nextIndex is the index of the first call, and is advanced past the calls returned.
+func geminiToolCalls(candidate models.GeminiCandidate, nextIndex *int) []models.ToolCall {
+	var toolCalls []models.ToolCall
+	for _, part := range candidate.Content.Parts {
+		if part.FunctionCall == nil {
+			continue
+		}
+
+		arguments := string(part.FunctionCall.Args)
+		if arguments == "" {
+			arguments = "{}"
+		}
+
+		call := models.ToolCall{
+			ID:   "call_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+			Type: "function",
+			Function: models.ToolCallFunction{
+				Name:      part.FunctionCall.Name,
+				Arguments: arguments,
+			},
+		}
+		if nextIndex != nil {
+			index := *nextIndex
+			call.Index = &index
+			*nextIndex++
+		}
+		toolCalls = append(toolCalls, call)
+	}
+	return toolCalls
+}
+
+// geminiFunctionResponse wraps a tool result as the JSON object Gemini expects
+func geminiFunctionResponse(content string) json.RawMessage {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+
+	wrapped, _ := json.Marshal(map[string]string{"content": content})
+	return wrapped
+}
+
+// geminiFinishReason maps Gemini finish reasons onto their OpenAI equivalents
+func geminiFinishReason(reason string) string {
+	switch reason {
+	case "":
+		return ""
+	case "STOP":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return "content_filter"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// stopSequences normalizes the OpenAI-style stop parameter (string or array) into a list
+func stopSequences(stop interface{}) []string {
+	switch v := stop.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		sequences := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				sequences = append(sequences, s)
+			}
+		}
+		return sequences
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"llm-inferra/internal/models"
+)
+
+func TestGeminiStreamParallelToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		// Gemini sends each parallel function call in its own chunk
+		fmt.Fprint(w, `data: {"candidates":[{"index":0,"content":{"role":"model","parts":[{"functionCall":{"name":"weather","args":{"city":"Paris"}}}]}}]}
+
+data: {"candidates":[{"index":0,"content":{"role":"model","parts":[{"functionCall":{"name":"weather","args":{"city":"Rome"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":6}}
+
+`)
+	}))
+	defer server.Close()
+
+	provider := NewGeminiProvider(server.URL)
+	stream, err := provider.StreamChatCompletion(testOpenAIContext(), &models.ChatCompletionRequest{
+		Model:    "gemini-2.0-flash",
+		Messages: []models.ChatMessage{{Role: "user", Content: models.TextContent("Weather in Paris and Rome?")}},
+	})
+	if err != nil {
+		t.Fatalf("StreamChatCompletion: %v", err)
+	}
+
+	accumulated := &streamAccumulator{}
+	for data := range stream {
+		accumulated.add(data)
+	}
+
+	calls := accumulated.toolCalls
+	if len(calls) != 2 {
+		t.Fatalf("tool calls = %+v, want 2 separate calls", calls)
+	}
+	if calls[0].Function.Arguments != `{"city":"Paris"}` || calls[1].Function.Arguments != `{"city":"Rome"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	if accumulated.finishReason != "tool_calls" {
+		t.Errorf("finish reason = %q, want tool_calls", accumulated.finishReason)
+	}
+}
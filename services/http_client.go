@@ -0,0 +1,21 @@
+package services
+
+import (
+	"net/http"
+	"time"
+)
+
+// Upstream response header timeouts. Only the wait for the response headers is bounded, a whole
+// request timeout would cut off streams that run longer.
+const (
+	defaultResponseHeaderTimeout = 30 * time.Second
+	// Self-hosted inference servers are often slower than hosted APIs
+	customResponseHeaderTimeout = 120 * time.Second
+)
+
+// newUpstreamHTTPClient returns the HTTP client adapters call providers with
+func newUpstreamHTTPClient(responseHeaderTimeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+	return &http.Client{Transport: transport}
+}
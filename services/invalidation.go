@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	// invalidationChannel carries cache and provider invalidations between gateway instances
+	invalidationChannel = "inferra:invalidate"
+	redisPublishTimeout = time.Second
+)
+
+type invalidationMessage struct {
+	Origin     string   `json:"origin"`
+	CacheKeys  []string `json:"cache_keys,omitempty"`
+	ProviderID uint     `json:"provider_id,omitempty"`
+}
+
+// InvalidationBus broadcasts invalidations to the other gateway instances through Redis pub/sub,
+// so revoked keys, suspended users and provider edits take effect everywhere within a second.
+// Delivery is best effort: an instance that misses a message catches up when its local cache
+// entries expire. A nil bus (no Redis configured) does nothing.
+type InvalidationBus struct {
+	redis     *redis.Client
+	origin    string
+	cache     *CacheService
+	providers *ProviderService
+
+	mu   sync.Mutex
+	stop context.CancelFunc
+}
+
+func NewInvalidationBus(client *redis.Client, cache *CacheService, providers *ProviderService) *InvalidationBus {
+	if client == nil {
+		return nil
+	}
+	return &InvalidationBus{
+		redis:     client,
+		origin:    uuid.New().String(),
+		cache:     cache,
+		providers: providers,
+	}
+}
+
+// PublishCacheKeys tells the other instances to drop their local copies of keys
+func (b *InvalidationBus) PublishCacheKeys(keys []string) {
+	if b == nil || len(keys) == 0 {
+		return
+	}
+	b.publish(invalidationMessage{CacheKeys: keys})
+}
+
+// PublishProvider tells the other instances to reload a provider's adapter and credentials
+func (b *InvalidationBus) PublishProvider(providerID uint) {
+	if b == nil {
+		return
+	}
+	b.publish(invalidationMessage{ProviderID: providerID})
+}
+
+func (b *InvalidationBus) publish(message invalidationMessage) {
+	message.Origin = b.origin
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisPublishTimeout)
+	defer cancel()
+	if err := b.redis.Publish(ctx, invalidationChannel, data).Err(); err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to publish invalidation: %v\n", err)
+	}
+}
+
+// Start applies invalidations published by other instances until Stop. The subscription
+// reconnects on its own when Redis goes away.
+func (b *InvalidationBus) Start() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	if b.stop != nil {
+		b.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.stop = cancel
+	b.mu.Unlock()
+
+	pubsub := b.redis.Subscribe(ctx, invalidationChannel)
+	go func() {
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				b.apply(msg.Payload)
+			}
+		}
+	}()
+}
+
+// Stop ends the subscription started by Start
+func (b *InvalidationBus) Stop() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stop != nil {
+		b.stop()
+		b.stop = nil
+	}
+}
+
+func (b *InvalidationBus) apply(payload string) {
+	var message invalidationMessage
+	if err := json.Unmarshal([]byte(payload), &message); err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Ignoring malformed invalidation: %v\n", err)
+		return
+	}
+	if message.Origin == b.origin {
+		return
+	}
+
+	if len(message.CacheKeys) > 0 && b.cache != nil {
+		b.cache.invalidateLocal(message.CacheKeys)
+	}
+	if message.ProviderID != 0 && b.providers != nil {
+		b.providers.reload(message.ProviderID)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"llm-inferra/internal/models"
+	"llm-inferra/internal/secrets"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
@@ -18,7 +19,12 @@ type LLMService struct {
 	db               *gorm.DB
 	redis            *redis.Client
 	cache            *CacheService
-	providers        map[models.ProviderType]models.LLMProvider
+	providers        *ProviderRegistry
+	breakers         *CircuitBreakerRegistry
+	credentials      *CredentialPool
+	budgets          *BudgetTracker
+	rateLimits       *RateLimiter
+	vectors          VectorIndex
 	apiKeyService    *APIKeyService
 	providerService  *ProviderService
 	analyticsService *AnalyticsService
@@ -28,8 +34,13 @@ func NewLLMService(db *gorm.DB, redis *redis.Client, apiKeyService *APIKeyServic
 	service := &LLMService{
 		db:               db,
 		redis:            redis,
-		cache:            NewCacheService(redis),
-		providers:        make(map[models.ProviderType]models.LLMProvider),
+		cache:            NewCacheService(newCacheBackend(redis)),
+		providers:        NewProviderRegistry(),
+		breakers:         NewCircuitBreakerRegistry(),
+		credentials:      NewCredentialPool(db),
+		budgets:          NewBudgetTracker(db),
+		rateLimits:       NewRateLimiter(),
+		vectors:          NewMemoryVectorIndex(defaultVectorIndexEntries),
 		apiKeyService:    apiKeyService,
 		providerService:  providerService,
 		analyticsService: analyticsService,
@@ -41,14 +52,28 @@ func NewLLMService(db *gorm.DB, redis *redis.Client, apiKeyService *APIKeyServic
 	return service
 }
 
+// newCacheBackend shares the cache through Redis, with a short-lived local copy, when it is
+// configured, and keeps it in process otherwise
+func newCacheBackend(redis *redis.Client) CacheBackend {
+	local := NewMemoryCacheBackend(defaultMemoryCacheEntries)
+	if redis == nil {
+		return local
+	}
+	return NewTieredCacheBackend(local, NewRedisCacheBackend(redis))
+}
+
 func (s *LLMService) initializeProviders() {
-	// Initialize Anthropic provider
-	s.providers[models.ProviderAnthropic] = NewAnthropicProvider(
-		"https://api.anthropic.com/v1",
-		"2023-06-01",
-	)
+	// Build one adapter per provider row from its BaseURL/APIVersion
+	if err := s.providers.LoadAll(s.db); err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to initialize providers: %v\n", err)
+	}
 
-	// TODO: Add other providers (OpenAI, Google, etc.)
+	// Rebuild adapters whenever a provider row is created, updated or deleted
+	if s.providerService != nil {
+		s.providerService.AddListener(s.providers)
+		s.providerService.AddListener(s.credentials)
+	}
 }
 
 // ValidateAPIKey - 为了向后兼容保留的方法，内部调用优化版本
@@ -78,31 +103,63 @@ func (s *LLMService) ChatCompletion(ctx *models.LLMRequestContext, req *models.C
 	}
 	ctx.Model = model
 
-	// Get provider implementation
-	provider, exists := s.providers[ctx.Provider.Type]
-	if !exists {
-		return nil, fmt.Errorf("provider %s not supported", ctx.Provider.Type)
+	if err := checkAPIKeyScope(ctx.APIKey, models.APIKeyScopeChat, model.ModelID); err != nil {
+		return nil, err
+	}
+
+	// Reject capabilities the model does not declare
+	if err := s.validateModelCapabilities(model, req); err != nil {
+		return nil, err
+	}
+
+	// Replay the cached response of an identical or similar deterministic request, at no cost
+	cached := newResponseCacheRequest(ctx, req)
+	if entry := s.lookupResponseCache(ctx, req, cached); entry != nil {
+		s.logCachedResponse(ctx, req, entry)
+		return &entry.Response, nil
+	}
+
+	// Check the cost budgets, holding the estimated cost until the request finishes
+	reservation, err := s.budgets.Reserve(ctx, estimateChatCost(model, req))
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the rate limits on the estimated input tokens, reconciled with the actual usage below
+	rateLease, err := s.rateLimits.Admit(ctx, estimateChatInputTokens(req))
+	if err != nil {
+		reservation.Release()
+		return nil, err
 	}
 
 	// Create request log (now that ctx.Model is set)
-	requestLog, err := s.createRequestLog(ctx, req)
+	requestLog, err := s.createRequestLog(ctx, req.Model, req)
 	if err != nil {
+		reservation.Release()
+		rateLease.Reconcile(0)
 		return nil, fmt.Errorf("failed to create request log: %w", err)
 	}
 
-	// Make the API call
+	// Make the API call, falling back along the model's fallback chain on retryable errors
 	startTime := time.Now()
-	response, err := provider.ChatCompletion(ctx, req)
+	var response *models.ChatCompletionResponse
+	provider, err := s.callWithFallback(ctx, req, requestLog.ID, func(provider models.LLMProvider, attemptCtx *models.LLMRequestContext, attemptReq *models.ChatCompletionRequest) error {
+		var callErr error
+		response, callErr = provider.ChatCompletion(attemptCtx, attemptReq)
+		return callErr
+	})
 	latency := time.Since(startTime)
 
 	// Update request log with response
 	if err != nil {
 		s.updateRequestLogError(requestLog.ID, err, int(latency.Milliseconds()))
+		reservation.Release()
+		rateLease.Reconcile(0)
 		return nil, err
 	}
 
-	// Calculate costs using provider interface
-	inputCost, outputCost, totalCost := provider.CalculateCost(&response.Usage, model)
+	// Calculate costs using provider interface (ctx.Model is the model that served the request)
+	inputCost, outputCost, totalCost := provider.CalculateCost(&response.Usage, ctx.Model)
 
 	// Update request log with success
 	err = s.updateRequestLogSuccess(requestLog.ID, response, &response.Usage, inputCost, outputCost, totalCost, int(latency.Milliseconds()))
@@ -111,6 +168,10 @@ func (s *LLMService) ChatCompletion(ctx *models.LLMRequestContext, req *models.C
 		// TODO: Replace with proper logger
 		fmt.Printf("Failed to update request log: %v\n", err)
 	}
+	s.credentials.RecordUsage(ctx.CredentialID, &response.Usage, totalCost)
+	reservation.Settle(totalCost)
+	rateLease.Reconcile(response.Usage.TotalTokens)
+	s.storeResponseCache(cached, response, totalCost)
 
 	return response, nil
 }
@@ -127,35 +188,86 @@ func (s *LLMService) StreamChatCompletion(ctx *models.LLMRequestContext, req *mo
 	}
 	ctx.Model = model
 
-	// Get provider implementation
-	provider, exists := s.providers[ctx.Provider.Type]
-	if !exists {
-		return nil, fmt.Errorf("provider %s not supported", ctx.Provider.Type)
+	if err := checkAPIKeyScope(ctx.APIKey, models.APIKeyScopeChat, model.ModelID); err != nil {
+		return nil, err
+	}
+
+	// Reject capabilities the model does not declare
+	if err := s.validateModelCapabilities(model, req); err != nil {
+		return nil, err
+	}
+
+	if err := validateStreamFormat(ctx.Provider, req); err != nil {
+		return nil, err
+	}
+
+	// Replay the cached response of an identical or similar deterministic request as a stream
+	cached := newResponseCacheRequest(ctx, req)
+	if entry := s.lookupResponseCache(ctx, req, cached); entry != nil {
+		s.logCachedResponse(ctx, req, entry)
+		return replayResponseStream(&entry.Response, req), nil
+	}
+
+	// Check the cost budgets, holding the estimated cost until the stream ends
+	reservation, err := s.budgets.Reserve(ctx, estimateChatCost(model, req))
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the rate limits on the estimated input tokens, reconciled when the stream ends
+	rateLease, err := s.rateLimits.Admit(ctx, estimateChatInputTokens(req))
+	if err != nil {
+		reservation.Release()
+		return nil, err
 	}
 
 	// Create request log (now that ctx.Model is set)
-	requestLog, err := s.createRequestLog(ctx, req)
+	requestLog, err := s.createRequestLog(ctx, req.Model, req)
 	if err != nil {
+		reservation.Release()
+		rateLease.Reconcile(0)
 		return nil, fmt.Errorf("failed to create request log: %w", err)
 	}
 
-	// Make the streaming API call
+	// Make the streaming API call. Fallbacks only apply until the upstream stream has started.
 	startTime := time.Now()
-	streamChan, err := provider.StreamChatCompletion(ctx, req)
+	var streamChan <-chan []byte
+	provider, err := s.callWithFallback(ctx, req, requestLog.ID, func(provider models.LLMProvider, attemptCtx *models.LLMRequestContext, attemptReq *models.ChatCompletionRequest) error {
+		var callErr error
+		streamChan, callErr = provider.StreamChatCompletion(attemptCtx, attemptReq)
+		return callErr
+	})
 	if err != nil {
 		latency := time.Since(startTime)
 		s.updateRequestLogError(requestLog.ID, err, int(latency.Milliseconds()))
+		reservation.Release()
+		rateLease.Reconcile(0)
 		return nil, err
 	}
+	model = ctx.Model
 
 	// Wrap the stream to track completion and extract usage
 	wrappedChan := make(chan []byte, 100)
 
 	go func() {
 		defer close(wrappedChan)
+		// The pooled credential stays in flight for the whole stream
+		defer s.credentials.Release(ctx.CredentialID)
+
+		// Streams that end without usage settle at an estimate, see below
+		var streamCost float64
+		defer func() { reservation.Settle(streamCost) }()
+		// Without usage the input estimate stands
+		streamTokens := rateLease.Estimate()
+		defer func() { rateLease.Reconcile(streamTokens) }()
 
 		var finalUsage *models.ChatCompletionUsage
+		// Rebuilds the response, for the response cache and to estimate unreported usage
+		accumulated := &streamAccumulator{}
+		clientGone := false
 
+		// The stream is read to the end even after the client has gone away: the provider keeps
+		// generating, and the usage it reports last still has to be billed
 		for data := range streamChan {
 			// Check if this is a usage update event
 			if usage := s.extractUsageFromSSE(data); usage != nil {
@@ -163,35 +275,92 @@ func (s *LLMService) StreamChatCompletion(ctx *models.LLMRequestContext, req *mo
 				// Don't forward usage events to client, just track internally
 				continue
 			}
+			accumulated.add(data)
+			if clientGone {
+				continue
+			}
 
-			// Forward regular content events to client
-			// Use non-blocking send to avoid goroutine hanging if client disconnects
+			// Forward regular content events to client, waiting for slow readers
 			select {
 			case wrappedChan <- data:
-			default:
-				// Client can't receive, likely disconnected - exit goroutine
-				return
+			case <-ctx.Done:
+				clientGone = true
 			}
 		}
 
 		// Update request log with final usage and cost information
 		latency := time.Since(startTime)
-		if finalUsage != nil {
-			// Calculate costs for streaming response using provider interface
-			inputCost, outputCost, totalCost := provider.CalculateCost(finalUsage, model)
+		reported := finalUsage != nil
+		if !reported {
+			// The provider reported no usage (e.g. a custom server ignoring include_usage): bill an
+			// estimate so the stream still counts against the cost limits
+			finalUsage = accumulated.estimatedUsage(model, req)
+		}
 
-			// Update with complete usage information
-			s.updateRequestLogStreamSuccess(requestLog.ID, finalUsage, inputCost, outputCost, totalCost, int(latency.Milliseconds()))
-		} else {
-			// Fallback: just mark as completed without usage info
-			s.updateRequestLogStreamComplete(requestLog.ID, int(latency.Milliseconds()))
+		// Calculate costs for streaming response using provider interface
+		inputCost, outputCost, totalCost := provider.CalculateCost(finalUsage, model)
+
+		// Update with complete usage information
+		s.updateRequestLogStreamSuccess(requestLog.ID, finalUsage, inputCost, outputCost, totalCost, int(latency.Milliseconds()))
+		s.credentials.RecordUsage(ctx.CredentialID, finalUsage, totalCost)
+		streamCost = totalCost
+		streamTokens = finalUsage.TotalTokens
+
+		// Completed streams fill the response cache like non-streaming requests
+		if reported && cached != nil {
+			if response := accumulated.response(finalUsage); response != nil {
+				s.storeResponseCache(cached, response, totalCost)
+			}
 		}
 	}()
 
 	return wrappedChan, nil
 }
 
-func (s *LLMService) createRequestLog(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest) (*models.LLMRequestLog, error) {
+// checkAPIKeyScope rejects requests outside the scopes and models a client key is limited to
+func checkAPIKeyScope(apiKey *models.APIKey, scope, modelName string) error {
+	if len(apiKey.Scopes) > 0 {
+		allowed := false
+		for _, s := range apiKey.Scopes {
+			if s == scope {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("permission denied: API key does not have the %s scope", scope)
+		}
+	}
+
+	if len(apiKey.AllowedModels) > 0 {
+		for _, m := range apiKey.AllowedModels {
+			if m == modelName {
+				return nil
+			}
+		}
+		return fmt.Errorf("permission denied: API key is not allowed to use model %s", modelName)
+	}
+	return nil
+}
+
+// validateModelCapabilities rejects requests that use features the model does not support
+func (s *LLMService) validateModelCapabilities(model *models.LLMModel, req *models.ChatCompletionRequest) error {
+	if requestUsesTools(req) && !model.SupportsFunctions {
+		return fmt.Errorf("request validation failed: model %s does not support tool calling", model.ModelID)
+	}
+
+	if requestHasImages(req) && !model.SupportsVision {
+		return fmt.Errorf("request validation failed: model %s does not support image inputs", model.ModelID)
+	}
+
+	if err := validateContentParts(req); err != nil {
+		return fmt.Errorf("request validation failed: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LLMService) createRequestLog(ctx *models.LLMRequestContext, modelName string, req interface{}) (*models.LLMRequestLog, error) {
 	requestData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request data: %w", err)
@@ -203,9 +372,10 @@ func (s *LLMService) createRequestLog(ctx *models.LLMRequestContext, req *models
 		APIKeyID:    ctx.APIKeyID,
 		ProviderID:  ctx.Provider.ID,
 		ModelID:     ctx.Model.ID,
-		ModelName:   req.Model,
+		ModelName:   modelName,
 		RequestData: requestData,
 		Status:      "pending",
+		CacheStatus: ctx.ResponseCacheStatus,
 		ClientIP:    ctx.ClientIP,
 		UserAgent:   ctx.UserAgent,
 	}
@@ -214,7 +384,7 @@ func (s *LLMService) createRequestLog(ctx *models.LLMRequestContext, req *models
 	return log, err
 }
 
-func (s *LLMService) updateRequestLogSuccess(logID uint, response *models.ChatCompletionResponse, usage *models.ChatCompletionUsage, inputCost, outputCost, totalCost float64, latencyMs int) error {
+func (s *LLMService) updateRequestLogSuccess(logID uint, response interface{}, usage *models.ChatCompletionUsage, inputCost, outputCost, totalCost float64, latencyMs int) error {
 	responseData, err := json.Marshal(response)
 	if err != nil {
 		return fmt.Errorf("failed to marshal response data: %w", err)
@@ -247,16 +417,6 @@ func (s *LLMService) updateRequestLogError(logID uint, err error, latencyMs int)
 	return s.db.Model(&models.LLMRequestLog{}).Where("id = ?", logID).Updates(updates).Error
 }
 
-func (s *LLMService) updateRequestLogStreamComplete(logID uint, latencyMs int) error {
-	updates := map[string]interface{}{
-		"status":      "completed",
-		"latency_ms":  latencyMs,
-		"http_status": 200,
-	}
-
-	return s.db.Model(&models.LLMRequestLog{}).Where("id = ?", logID).Updates(updates).Error
-}
-
 func (s *LLMService) updateRequestLogStreamSuccess(logID uint, usage *models.ChatCompletionUsage, inputCost, outputCost, totalCost float64, latencyMs int) error {
 	updates := map[string]interface{}{
 		"status":        "completed",
@@ -316,6 +476,26 @@ func (s *LLMService) extractUsageFromSSE(data []byte) *models.ChatCompletionUsag
 // getDailyUsage 和 getMonthlyUsage 已被 getBatchUsage 替代
 // 这些函数已移除，因为新的 getBatchUsage 方法使用单个查询获取所有使用量数据，性能更好
 
+// Cache returns the cache used for API key authentication
+func (s *LLMService) Cache() *CacheService {
+	return s.cache
+}
+
+// SetVectorIndex replaces the in-memory index used by the semantic cache
+func (s *LLMService) SetVectorIndex(index VectorIndex) {
+	s.vectors = index
+}
+
+// Budgets returns the cost limit tracker
+func (s *LLMService) Budgets() *BudgetTracker {
+	return s.budgets
+}
+
+// CircuitBreakers returns the per provider and model circuit breakers
+func (s *LLMService) CircuitBreakers() *CircuitBreakerRegistry {
+	return s.breakers
+}
+
 // GetDB returns the database instance for external access
 func (s *LLMService) GetDB() *gorm.DB {
 	return s.db
@@ -325,15 +505,22 @@ func (s *LLMService) GetDB() *gorm.DB {
 func (s *LLMService) ValidateAPIKeyOptimized(apiKey string) (*models.LLMRequestContext, error) {
 	ctx := context.Background()
 
+	// 只用摘要查找和缓存，原始密钥不进入数据库查询或 Redis
+	keyHash := secrets.Digest(apiKey)
+
 	// 1. 尝试从缓存服务获取API Key信息
-	if cachedAPIKey, found := s.cache.GetAPIKey(ctx, apiKey); found {
+	if cachedAPIKey, found := s.cache.GetAPIKey(ctx, keyHash); found {
+		// 缓存中不含密钥本身；直通密钥的上游密钥就是摘要匹配的请求密钥，无需查库解密
+		if !cachedAPIKey.Virtual {
+			cachedAPIKey.KeyValue = apiKey
+		}
 		// 缓存命中，直接验证使用限制
 		return s.validateUsageLimitsOptimizedWithUsage(cachedAPIKey, nil)
 	}
 
 	// 2. 缓存未命中，从数据库查询
 	var dbAPIKey models.APIKey
-	err := s.db.Preload("User").Preload("Provider").First(&dbAPIKey, "key_value = ? AND status = ?", apiKey, "active").Error
+	err := s.db.Preload("User").Preload("Provider").First(&dbAPIKey, "key_hash = ? AND status = ?", keyHash, "active").Error
 	if err != nil {
 		return nil, fmt.Errorf("invalid API key")
 	}
@@ -344,25 +531,26 @@ func (s *LLMService) ValidateAPIKeyOptimized(apiKey string) (*models.LLMRequestC
 	}
 
 	// 4. 预先获取使用量统计，准备批量缓存
+	// 直通密钥同样按摘要缓存，KeyValue 不参与序列化，上游密钥不会写入缓存
 	var usage *UsageCounts
 	if dbAPIKey.DailyRequestLimit > 0 || dbAPIKey.MonthlyRequestLimit > 0 {
 		usage, err = s.getBatchUsageFromDB(dbAPIKey.ID)
 		if err != nil {
 			// 如果获取使用量失败，仍然缓存API Key，但不缓存使用量
-			if err := s.cache.SetAPIKey(ctx, apiKey, &dbAPIKey, 5*time.Minute); err != nil {
+			if err := s.cache.SetAPIKey(ctx, keyHash, &dbAPIKey, 5*time.Minute); err != nil {
 				// TODO: Replace with proper logger
 				fmt.Printf("Failed to cache API key: %v\n", err)
 			}
 		} else {
 			// 同时缓存API Key和使用量统计，确保数据一致性
-			if err := s.cache.SetAPIKeyWithUsage(ctx, apiKey, &dbAPIKey, usage, 5*time.Minute, 1*time.Minute); err != nil {
+			if err := s.cache.SetAPIKeyWithUsage(ctx, keyHash, &dbAPIKey, usage, 5*time.Minute, 1*time.Minute); err != nil {
 				// TODO: Replace with proper logger
 				fmt.Printf("Failed to batch cache API key and usage: %v\n", err)
 			}
 		}
 	} else {
 		// 如果没有使用限制，只缓存API Key
-		if err := s.cache.SetAPIKey(ctx, apiKey, &dbAPIKey, 5*time.Minute); err != nil {
+		if err := s.cache.SetAPIKey(ctx, keyHash, &dbAPIKey, 5*time.Minute); err != nil {
 			// TODO: Replace with proper logger
 			fmt.Printf("Failed to cache API key: %v\n", err)
 		}
@@ -372,8 +560,23 @@ func (s *LLMService) ValidateAPIKeyOptimized(apiKey string) (*models.LLMRequestC
 	return s.validateUsageLimitsOptimizedWithUsage(&dbAPIKey, usage)
 }
 
+// APIKeyOwner 按摘要查找API Key所属用户，供认证前运行的限流中间件使用。
+// 只查缓存不查库，否则随机密钥每个请求都会在限流前打到数据库；未命中的请求按IP限流，
+// 密钥首次认证后即写入缓存
+func (s *LLMService) APIKeyOwner(keyHash string) (uint, bool) {
+	if cachedAPIKey, found := s.cache.GetAPIKey(context.Background(), keyHash); found {
+		return cachedAPIKey.UserID, true
+	}
+	return 0, false
+}
+
 // validateUsageLimitsOptimizedWithUsage - 使用预获取的使用量数据验证限制
 func (s *LLMService) validateUsageLimitsOptimizedWithUsage(dbAPIKey *models.APIKey, usage *UsageCounts) (*models.LLMRequestContext, error) {
+	// 用户被停用或暂停后，其所有API Key立即失效
+	if status := dbAPIKey.User.Status; status != "" && status != models.StatusActive {
+		return nil, fmt.Errorf("user account is %s", status)
+	}
+
 	// 如果需要检查使用限制且usage为空，则从缓存或数据库获取
 	if (dbAPIKey.DailyRequestLimit > 0 || dbAPIKey.MonthlyRequestLimit > 0) && usage == nil {
 		ctx := context.Background()
@@ -410,6 +613,11 @@ func (s *LLMService) validateUsageLimitsOptimizedWithUsage(dbAPIKey *models.APIK
 		}
 	}
 
+	// 检查用户级限制，按该用户所有API Key合计
+	if err := s.validateUserUsageLimits(&dbAPIKey.User); err != nil {
+		return nil, err
+	}
+
 	// 创建请求上下文
 	requestCtx := &models.LLMRequestContext{
 		RequestID: uuid.New().String(),
@@ -423,6 +631,36 @@ func (s *LLMService) validateUsageLimitsOptimizedWithUsage(dbAPIKey *models.APIK
 	return requestCtx, nil
 }
 
+// validateUserUsageLimits - 检查用户的日、月请求数限制
+func (s *LLMService) validateUserUsageLimits(user *models.User) error {
+	if user.ID == 0 || (user.DailyRequestLimit <= 0 && user.MonthlyRequestLimit <= 0) {
+		return nil
+	}
+
+	ctx := context.Background()
+	usage, found := s.cache.GetUserUsageCount(ctx, user.ID)
+	if !found {
+		var err error
+		usage, err = s.getUserUsageFromDB(user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check usage limits: %w", err)
+		}
+
+		if err := s.cache.SetUserUsageCount(ctx, user.ID, usage, 1*time.Minute); err != nil {
+			// TODO: Replace with proper logger
+			fmt.Printf("Failed to cache user usage count: %v\n", err)
+		}
+	}
+
+	if user.DailyRequestLimit > 0 && usage.DailyCount >= user.DailyRequestLimit {
+		return fmt.Errorf("daily request limit exceeded for user")
+	}
+	if user.MonthlyRequestLimit > 0 && usage.MonthlyCount >= user.MonthlyRequestLimit {
+		return fmt.Errorf("monthly request limit exceeded for user")
+	}
+	return nil
+}
+
 // UsageCounts 使用量统计结构
 type UsageCounts struct {
 	DailyCount   int64
@@ -431,11 +669,17 @@ type UsageCounts struct {
 
 // getBatchUsageFromDB - 从数据库批量获取使用量统计，使用单个UNION查询
 func (s *LLMService) getBatchUsageFromDB(apiKeyID uint) (*UsageCounts, error) {
-	now := time.Now()
-	today := now.Truncate(24 * time.Hour)
-	tomorrow := today.Add(24 * time.Hour)
-	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	monthEnd := monthStart.AddDate(0, 1, 0)
+	return s.getUsageCountsFromDB("api_key_id", apiKeyID)
+}
+
+// getUserUsageFromDB - 从数据库获取用户所有API Key的合计使用量
+func (s *LLMService) getUserUsageFromDB(userID uint) (*UsageCounts, error) {
+	return s.getUsageCountsFromDB("user_id", userID)
+}
+
+// getUsageCountsFromDB - 按 api_key_id 或 user_id 统计日、月请求数
+func (s *LLMService) getUsageCountsFromDB(column string, id uint) (*UsageCounts, error) {
+	today, tomorrow, monthStart, monthEnd := usageWindows(time.Now())
 
 	var results []struct {
 		IsDaily bool
@@ -443,17 +687,17 @@ func (s *LLMService) getBatchUsageFromDB(apiKeyID uint) (*UsageCounts, error) {
 	}
 
 	// 使用UNION查询同时获取日、月使用量
-	query := `
+	query := fmt.Sprintf(`
 		SELECT true as is_daily, COUNT(*) as count
 		FROM llm_request_logs 
-		WHERE api_key_id = ? AND created_at >= ? AND created_at < ?
+		WHERE %[1]s = ? AND created_at >= ? AND created_at < ?
 		UNION ALL
 		SELECT false as is_daily, COUNT(*) as count  
 		FROM llm_request_logs
-		WHERE api_key_id = ? AND created_at >= ? AND created_at < ?
-	`
+		WHERE %[1]s = ? AND created_at >= ? AND created_at < ?
+	`, column)
 
-	err := s.db.Raw(query, apiKeyID, today, tomorrow, apiKeyID, monthStart, monthEnd).Scan(&results).Error
+	err := s.db.Raw(query, id, today, tomorrow, id, monthStart, monthEnd).Scan(&results).Error
 	if err != nil {
 		return nil, err
 	}
@@ -8,18 +8,30 @@ import (
 
 	"llm-inferra/internal/models"
 
-	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
 // CacheService 缓存服务，用于优化数据库查询
 type CacheService struct {
-	redis *redis.Client
+	backend       CacheBackend
+	invalidations *InvalidationBus
 }
 
-// NewCacheService 创建缓存服务
-func NewCacheService(redis *redis.Client) *CacheService {
-	return &CacheService{redis: redis}
+// NewCacheService 创建缓存服务，未配置 Redis 时使用进程内 LRU 缓存
+func NewCacheService(backend CacheBackend) *CacheService {
+	return &CacheService{backend: backend}
+}
+
+// SetInvalidationBus 设置跨实例失效广播，删除的缓存键会通知其他实例
+func (c *CacheService) SetInvalidationBus(bus *InvalidationBus) {
+	c.invalidations = bus
+}
+
+// invalidateLocal 删除其他实例已失效的本地缓存副本
+func (c *CacheService) invalidateLocal(keys []string) {
+	if local, ok := c.backend.(interface{ DeleteLocal(keys ...string) }); ok {
+		local.DeleteLocal(keys...)
+	}
 }
 
 // APIKeyCacheEntry API Key缓存条目
@@ -36,27 +48,23 @@ type UsageCacheEntry struct {
 	CachedAt     time.Time `json:"cached_at"`
 }
 
-// GetAPIKey 从缓存获取API Key
-func (c *CacheService) GetAPIKey(ctx context.Context, apiKey string) (*models.APIKey, bool) {
-	if c.redis == nil {
-		return nil, false
-	}
-
-	cacheKey := fmt.Sprintf("api_key:%s", apiKey)
-	data, err := c.redis.Get(ctx, cacheKey).Result()
-	if err != nil {
+// GetAPIKey 从缓存获取API Key，keyHash 为密钥的 SHA-256 摘要
+func (c *CacheService) GetAPIKey(ctx context.Context, keyHash string) (*models.APIKey, bool) {
+	cacheKey := fmt.Sprintf("api_key:%s", keyHash)
+	data, found, err := c.backend.Get(ctx, cacheKey)
+	if err != nil || !found {
 		return nil, false
 	}
 
 	var entry APIKeyCacheEntry
-	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+	if err := json.Unmarshal(data, &entry); err != nil {
 		return nil, false
 	}
 
 	// 检查是否过期
 	if entry.ExpiresAt != nil && entry.ExpiresAt.Before(time.Now()) {
 		// 删除过期缓存
-		c.redis.Del(ctx, cacheKey)
+		c.backend.Delete(ctx, cacheKey)
 		return nil, false
 	}
 
@@ -65,11 +73,7 @@ func (c *CacheService) GetAPIKey(ctx context.Context, apiKey string) (*models.AP
 
 // SetAPIKey 将API Key存入缓存
 // 推荐使用 SetAPIKeyWithUsage 或 SetBatch 进行批量操作以提高性能
-func (c *CacheService) SetAPIKey(ctx context.Context, apiKey string, dbAPIKey *models.APIKey, ttl time.Duration) error {
-	if c.redis == nil {
-		return nil
-	}
-
+func (c *CacheService) SetAPIKey(ctx context.Context, keyHash string, dbAPIKey *models.APIKey, ttl time.Duration) error {
 	entry := APIKeyCacheEntry{
 		APIKey:    *dbAPIKey,
 		CachedAt:  time.Now(),
@@ -81,24 +85,20 @@ func (c *CacheService) SetAPIKey(ctx context.Context, apiKey string, dbAPIKey *m
 		return err
 	}
 
-	cacheKey := fmt.Sprintf("api_key:%s", apiKey)
-	return c.redis.Set(ctx, cacheKey, data, ttl).Err()
+	cacheKey := fmt.Sprintf("api_key:%s", keyHash)
+	return c.backend.Set(ctx, cacheKey, data, ttl)
 }
 
 // GetUsageCount 从缓存获取使用量统计
 func (c *CacheService) GetUsageCount(ctx context.Context, apiKeyID uint) (*UsageCounts, bool) {
-	if c.redis == nil {
-		return nil, false
-	}
-
 	cacheKey := fmt.Sprintf("usage:%d", apiKeyID)
-	data, err := c.redis.Get(ctx, cacheKey).Result()
-	if err != nil {
+	data, found, err := c.backend.Get(ctx, cacheKey)
+	if err != nil || !found {
 		return nil, false
 	}
 
 	var entry UsageCacheEntry
-	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+	if err := json.Unmarshal(data, &entry); err != nil {
 		return nil, false
 	}
 
@@ -113,10 +113,42 @@ func (c *CacheService) GetUsageCount(ctx context.Context, apiKeyID uint) (*Usage
 // SetUsageCount 将使用量统计存入缓存
 // 推荐使用 SetAPIKeyWithUsage 或 SetBatch 进行批量操作以提高性能
 func (c *CacheService) SetUsageCount(ctx context.Context, apiKeyID uint, usage *UsageCounts, ttl time.Duration) error {
-	if c.redis == nil {
-		return nil
+	entry := UsageCacheEntry{
+		DailyCount:   usage.DailyCount,
+		MonthlyCount: usage.MonthlyCount,
+		CachedAt:     time.Now(),
 	}
 
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	cacheKey := fmt.Sprintf("usage:%d", apiKeyID)
+	return c.backend.Set(ctx, cacheKey, data, ttl)
+}
+
+// GetUserUsageCount 从缓存获取用户所有API Key的合计使用量
+func (c *CacheService) GetUserUsageCount(ctx context.Context, userID uint) (*UsageCounts, bool) {
+	cacheKey := fmt.Sprintf("user_usage:%d", userID)
+	data, found, err := c.backend.Get(ctx, cacheKey)
+	if err != nil || !found {
+		return nil, false
+	}
+
+	var entry UsageCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+
+	return &UsageCounts{
+		DailyCount:   entry.DailyCount,
+		MonthlyCount: entry.MonthlyCount,
+	}, true
+}
+
+// SetUserUsageCount 将用户合计使用量存入缓存
+func (c *CacheService) SetUserUsageCount(ctx context.Context, userID uint, usage *UsageCounts, ttl time.Duration) error {
 	entry := UsageCacheEntry{
 		DailyCount:   usage.DailyCount,
 		MonthlyCount: usage.MonthlyCount,
@@ -128,18 +160,60 @@ func (c *CacheService) SetUsageCount(ctx context.Context, apiKeyID uint, usage *
 		return err
 	}
 
-	cacheKey := fmt.Sprintf("usage:%d", apiKeyID)
-	return c.redis.Set(ctx, cacheKey, data, ttl).Err()
+	cacheKey := fmt.Sprintf("user_usage:%d", userID)
+	return c.backend.Set(ctx, cacheKey, data, ttl)
 }
 
-// InvalidateAPIKeyAndUsage 同时使API Key和使用量缓存失效
-func (c *CacheService) InvalidateAPIKeyAndUsage(ctx context.Context, apiKey string, apiKeyID uint) error {
-	if c.redis == nil {
-		return nil
+// ResponseCacheEntry 响应缓存条目，Cost 为原始请求的费用
+type ResponseCacheEntry struct {
+	Response models.ChatCompletionResponse `json:"response"`
+	Cost     float64                       `json:"cost"`
+	CachedAt time.Time                     `json:"cached_at"`
+}
+
+// GetResponse 从缓存获取请求的响应
+func (c *CacheService) GetResponse(ctx context.Context, cacheKey string) (*ResponseCacheEntry, bool) {
+	data, found, err := c.backend.Get(ctx, cacheKey)
+	if err != nil || !found {
+		return nil, false
+	}
+
+	var entry ResponseCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// SetResponse 将响应存入缓存，按 API Key 的策略设置过期时间
+func (c *CacheService) SetResponse(ctx context.Context, cacheKey string, response *models.ChatCompletionResponse, cost float64, ttl time.Duration) error {
+	entry := ResponseCacheEntry{
+		Response: *response,
+		Cost:     cost,
+		CachedAt: time.Now(),
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return c.backend.Set(ctx, cacheKey, data, ttl)
+}
+
+// InvalidateUser 使用户合计使用量及其所有API Key的缓存失效，用于用户限额变更后立即生效
+func (c *CacheService) InvalidateUser(ctx context.Context, userID uint, apiKeys []models.APIKey) error {
+	keys := []string{fmt.Sprintf("user_usage:%d", userID)}
+	for _, apiKey := range apiKeys {
+		keys = append(keys, fmt.Sprintf("api_key:%s", apiKey.KeyHash), fmt.Sprintf("usage:%d", apiKey.ID))
 	}
 
+	return c.InvalidateBatch(ctx, keys)
+}
+
+// InvalidateAPIKeyAndUsage 同时使API Key和使用量缓存失效
+func (c *CacheService) InvalidateAPIKeyAndUsage(ctx context.Context, keyHash string, apiKeyID uint) error {
 	keys := []string{
-		fmt.Sprintf("api_key:%s", apiKey),
+		fmt.Sprintf("api_key:%s", keyHash),
 		fmt.Sprintf("usage:%d", apiKeyID),
 	}
 
@@ -153,38 +227,27 @@ type BatchCacheEntry struct {
 	TTL   time.Duration
 }
 
-// SetBatch 批量设置缓存，使用Redis Pipeline减少网络往返
+// SetBatch 批量设置缓存，Redis 后端使用Pipeline减少网络往返
 func (c *CacheService) SetBatch(ctx context.Context, entries []BatchCacheEntry) error {
-	if c.redis == nil {
-		return nil
-	}
-
 	if len(entries) == 0 {
 		return nil
 	}
 
-	// 使用Pipeline批量操作
-	pipe := c.redis.Pipeline()
-
+	values := make(map[string]cacheValue, len(entries))
 	for _, entry := range entries {
 		data, err := json.Marshal(entry.Value)
 		if err != nil {
 			return fmt.Errorf("failed to marshal cache entry %s: %w", entry.Key, err)
 		}
-		pipe.Set(ctx, entry.Key, data, entry.TTL)
+		values[entry.Key] = cacheValue{data: data, ttl: entry.TTL}
 	}
 
 	// 执行批量操作
-	_, err := pipe.Exec(ctx)
-	return err
+	return c.backend.SetMany(ctx, values)
 }
 
 // SetAPIKeyWithUsage 原子性地设置API Key和其使用量统计
-func (c *CacheService) SetAPIKeyWithUsage(ctx context.Context, apiKey string, dbAPIKey *models.APIKey, usage *UsageCounts, apiKeyTTL, usageTTL time.Duration) error {
-	if c.redis == nil {
-		return nil
-	}
-
+func (c *CacheService) SetAPIKeyWithUsage(ctx context.Context, keyHash string, dbAPIKey *models.APIKey, usage *UsageCounts, apiKeyTTL, usageTTL time.Duration) error {
 	// 准备API Key缓存条目
 	apiKeyEntry := APIKeyCacheEntry{
 		APIKey:    *dbAPIKey,
@@ -202,7 +265,7 @@ func (c *CacheService) SetAPIKeyWithUsage(ctx context.Context, apiKey string, db
 	// 批量操作
 	entries := []BatchCacheEntry{
 		{
-			Key:   fmt.Sprintf("api_key:%s", apiKey),
+			Key:   fmt.Sprintf("api_key:%s", keyHash),
 			Value: apiKeyEntry,
 			TTL:   apiKeyTTL,
 		},
@@ -218,35 +281,18 @@ func (c *CacheService) SetAPIKeyWithUsage(ctx context.Context, apiKey string, db
 
 // GetBatch 批量获取缓存，减少网络往返
 func (c *CacheService) GetBatch(ctx context.Context, keys []string) (map[string]interface{}, error) {
-	if c.redis == nil {
-		return nil, fmt.Errorf("redis client is nil")
-	}
-
 	if len(keys) == 0 {
 		return make(map[string]interface{}), nil
 	}
 
-	// 使用Pipeline批量获取
-	pipe := c.redis.Pipeline()
-
-	for _, key := range keys {
-		pipe.Get(ctx, key)
-	}
-
-	results, err := pipe.Exec(ctx)
+	values, err := c.backend.GetMany(ctx, keys)
 	if err != nil {
 		return nil, err
 	}
 
-	resultMap := make(map[string]interface{})
-
-	for i, result := range results {
-		if cmd, ok := result.(*redis.StringCmd); ok {
-			value, err := cmd.Result()
-			if err == nil {
-				resultMap[keys[i]] = value
-			}
-		}
+	resultMap := make(map[string]interface{}, len(values))
+	for key, data := range values {
+		resultMap[key] = string(data)
 	}
 
 	return resultMap, nil
@@ -254,33 +300,21 @@ func (c *CacheService) GetBatch(ctx context.Context, keys []string) (map[string]
 
 // InvalidateBatch 批量删除缓存
 func (c *CacheService) InvalidateBatch(ctx context.Context, keys []string) error {
-	if c.redis == nil {
-		return nil
-	}
-
 	if len(keys) == 0 {
 		return nil
 	}
 
-	// 使用Pipeline批量删除
-	pipe := c.redis.Pipeline()
-
-	for _, key := range keys {
-		pipe.Del(ctx, key)
-	}
-
-	_, err := pipe.Exec(ctx)
+	// 即使Redis删除失败也通知其他实例丢弃本地副本
+	err := c.backend.Delete(ctx, keys...)
+	c.invalidations.PublishCacheKeys(keys)
 	return err
 }
 
 // WarmupAPIKeyCache 预热API Key缓存 - 优化版本使用批量操作
 func (c *CacheService) WarmupAPIKeyCache(ctx context.Context, db *gorm.DB) error {
-	if c.redis == nil {
-		return nil
-	}
-
+	// 直通密钥也预热，KeyValue 不参与序列化，命中时取自请求密钥
 	var apiKeys []models.APIKey
-	err := db.Preload("User").Preload("Provider").
+	err := db.Preload("User").Preload("Provider").Omit("key_value").
 		Where("status = ?", "active").
 		Find(&apiKeys).Error
 	if err != nil {
@@ -298,7 +332,7 @@ func (c *CacheService) WarmupAPIKeyCache(ctx context.Context, db *gorm.DB) error
 		}
 
 		entries = append(entries, BatchCacheEntry{
-			Key:   fmt.Sprintf("api_key:%s", apiKey.KeyValue),
+			Key:   fmt.Sprintf("api_key:%s", apiKey.KeyHash),
 			Value: entry,
 			TTL:   5 * time.Minute,
 		})
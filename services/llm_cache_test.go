@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"llm-inferra/internal/models"
+	"llm-inferra/internal/secrets"
+)
+
+func TestPassThroughKeyCachedWithoutKeyValue(t *testing.T) {
+	backend := NewMemoryCacheBackend(100)
+	service := &LLMService{cache: NewCacheService(backend)}
+
+	const upstreamKey = "sk-upstream-secret"
+	keyHash := secrets.Digest(upstreamKey)
+	apiKey := &models.APIKey{ID: 7, UserID: 3, KeyValue: upstreamKey, Status: models.APIKeyStatusActive}
+	if err := service.cache.SetAPIKey(context.Background(), keyHash, apiKey, time.Minute); err != nil {
+		t.Fatalf("SetAPIKey: %v", err)
+	}
+
+	data, found, _ := backend.Get(context.Background(), "api_key:"+keyHash)
+	if !found {
+		t.Fatal("pass-through key not cached")
+	}
+	if strings.Contains(string(data), upstreamKey) {
+		t.Fatalf("cache entry holds the upstream key: %s", data)
+	}
+
+	// A cache hit restores the upstream key from the key the client presented
+	ctx, err := service.ValidateAPIKeyOptimized(upstreamKey)
+	if err != nil {
+		t.Fatalf("ValidateAPIKeyOptimized: %v", err)
+	}
+	if ctx.APIKey.KeyValue != upstreamKey || ctx.APIKeyID != 7 {
+		t.Errorf("context key = %+v", ctx.APIKey)
+	}
+}
+
+func TestAPIKeyOwnerOnlyUsesCache(t *testing.T) {
+	// No database: a lookup that reached it would panic
+	service := &LLMService{cache: NewCacheService(NewMemoryCacheBackend(100))}
+
+	known := secrets.Digest("sk-inferra-known")
+	if err := service.cache.SetAPIKey(context.Background(), known, &models.APIKey{ID: 1, UserID: 9}, time.Minute); err != nil {
+		t.Fatalf("SetAPIKey: %v", err)
+	}
+
+	if userID, ok := service.APIKeyOwner(known); !ok || userID != 9 {
+		t.Errorf("APIKeyOwner(known) = %d, %v, want 9, true", userID, ok)
+	}
+	if _, ok := service.APIKeyOwner(secrets.Digest("sk-inferra-random")); ok {
+		t.Error("APIKeyOwner resolved an unknown key")
+	}
+}
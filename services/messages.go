@@ -0,0 +1,281 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"llm-inferra/internal/models"
+)
+
+// Messages handles a native Anthropic Messages API request. The request is converted into the
+// unified format so it goes through the same model lookup, logging and cost accounting as
+// /v1/chat/completions, and the result is converted back into a Messages response.
+func (s *LLMService) Messages(ctx *models.LLMRequestContext, req *models.MessagesRequest, clientIP, userAgent string) (*models.AnthropicResponse, error) {
+	chatReq, err := messagesToChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.ChatCompletion(ctx, chatReq, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	return chatResponseToMessages(response), nil
+}
+
+// StreamMessages streams a native Messages request. Anthropic events are passed through as-is,
+// the unified chunk stream of other providers is translated into Messages events.
+func (s *LLMService) StreamMessages(ctx *models.LLMRequestContext, req *models.MessagesRequest, clientIP, userAgent string) (<-chan []byte, error) {
+	chatReq, err := messagesToChatRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	chatReq.Stream = true
+
+	if ctx.Provider.Type == models.ProviderAnthropic {
+		chatReq.StreamFormat = models.StreamFormatAnthropic
+		return s.StreamChatCompletion(ctx, chatReq, clientIP, userAgent)
+	}
+
+	// The final usage chunk fills in message_delta's token counts
+	chatReq.StreamOptions = &models.StreamOptions{IncludeUsage: true}
+	stream, err := s.StreamChatCompletion(ctx, chatReq, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	return translateMessagesStream(stream, chatReq.Model, ctx.Done), nil
+}
+
+// messagesToChatRequest converts a Messages request into the unified request.
+// tool_use blocks become assistant tool calls and tool_result blocks become "tool" messages.
+func messagesToChatRequest(req *models.MessagesRequest) (*models.ChatCompletionRequest, error) {
+	if req.Model == "" {
+		return nil, fmt.Errorf("request validation failed: model is required")
+	}
+	if len(req.Messages) == 0 {
+		return nil, fmt.Errorf("request validation failed: messages cannot be empty")
+	}
+	if req.MaxTokens <= 0 {
+		return nil, fmt.Errorf("request validation failed: max_tokens must be greater than 0")
+	}
+
+	maxTokens := req.MaxTokens
+	chatReq := &models.ChatCompletionRequest{
+		Model:       req.Model,
+		MaxTokens:   &maxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		TopK:        req.TopK,
+		Stream:      req.Stream,
+		System:      messagesSystemText(req.System),
+		Metadata:    req.Metadata,
+	}
+
+	if len(req.StopSequences) > 0 {
+		chatReq.Stop = req.StopSequences
+	}
+
+	for i, msg := range req.Messages {
+		switch msg.Role {
+		case "user":
+			chatReq.Messages = append(chatReq.Messages, messagesUserTurn(msg.Content)...)
+		case "assistant":
+			chatReq.Messages = append(chatReq.Messages, messagesAssistantTurn(msg.Content))
+		default:
+			return nil, fmt.Errorf("request validation failed: message %d: invalid role '%s'", i, msg.Role)
+		}
+	}
+
+	for _, tool := range req.Tools {
+		chatReq.Tools = append(chatReq.Tools, models.Tool{
+			Type: "function",
+			Function: models.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+
+	if req.ToolChoice != nil {
+		switch req.ToolChoice.Type {
+		case "auto", "none":
+			chatReq.ToolChoice = req.ToolChoice.Type
+		case "any":
+			chatReq.ToolChoice = "required"
+		case "tool":
+			chatReq.ToolChoice = map[string]interface{}{
+				"type":     "function",
+				"function": map[string]interface{}{"name": req.ToolChoice.Name},
+			}
+		default:
+			return nil, fmt.Errorf("request validation failed: unsupported tool_choice type '%s'", req.ToolChoice.Type)
+		}
+	}
+
+	return chatReq, nil
+}
+
+// messagesSystemText flattens the system prompt, which may be a string or text blocks
+func messagesSystemText(system models.MessagesContent) string {
+	if system.Blocks == nil {
+		return system.Text
+	}
+
+	texts := make([]string, 0, len(system.Blocks))
+	for _, block := range system.Blocks {
+		if block.Type == "text" {
+			texts = append(texts, block.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// messagesUserTurn splits a user turn into "tool" messages for its tool_result blocks,
+// followed by a user message with the remaining text and image blocks
+func messagesUserTurn(content models.MessagesContent) []models.ChatMessage {
+	if content.Blocks == nil {
+		return []models.ChatMessage{{Role: "user", Content: models.TextContent(content.Text)}}
+	}
+
+	var messages []models.ChatMessage
+	var parts []models.ContentPart
+	for _, block := range content.Blocks {
+		if block.Type == "tool_result" {
+			result := models.MessageContent{}
+			if block.Content != nil {
+				result = messagesContentParts(*block.Content)
+			}
+			messages = append(messages, models.ChatMessage{
+				Role:       "tool",
+				Content:    result,
+				ToolCallID: block.ToolUseID,
+			})
+			continue
+		}
+		parts = append(parts, messagesContentParts(models.MessagesContent{Blocks: []models.MessagesContentBlock{block}}).Parts...)
+	}
+
+	if len(parts) > 0 {
+		messages = append(messages, models.ChatMessage{Role: "user", Content: models.MessageContent{Parts: parts}})
+	}
+	return messages
+}
+
+// messagesAssistantTurn converts an assistant turn, turning tool_use blocks into tool calls
+func messagesAssistantTurn(content models.MessagesContent) models.ChatMessage {
+	if content.Blocks == nil {
+		return models.ChatMessage{Role: "assistant", Content: models.TextContent(content.Text)}
+	}
+
+	var text strings.Builder
+	var toolCalls []models.ToolCall
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			arguments := string(block.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			toolCalls = append(toolCalls, models.ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: models.ToolCallFunction{
+					Name:      block.Name,
+					Arguments: arguments,
+				},
+			})
+		}
+	}
+
+	return models.ChatMessage{
+		Role:      "assistant",
+		Content:   models.TextContent(text.String()),
+		ToolCalls: toolCalls,
+	}
+}
+
+// messagesContentParts converts text and image blocks into unified content parts
+func messagesContentParts(content models.MessagesContent) models.MessageContent {
+	if content.Blocks == nil {
+		return models.TextContent(content.Text)
+	}
+
+	parts := make([]models.ContentPart, 0, len(content.Blocks))
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case "text":
+			parts = append(parts, models.ContentPart{Type: models.ContentPartText, Text: block.Text})
+		case "image":
+			if block.Source == nil {
+				continue
+			}
+			if block.Source.Type == "url" {
+				parts = append(parts, models.ContentPart{
+					Type:     models.ContentPartImageURL,
+					ImageURL: &models.ImageURL{URL: block.Source.URL},
+				})
+				continue
+			}
+			parts = append(parts, models.ContentPart{
+				Type: models.ContentPartImage,
+				Source: &models.ImageSource{
+					Type:      block.Source.Type,
+					MediaType: block.Source.MediaType,
+					Data:      block.Source.Data,
+				},
+			})
+		}
+	}
+	return models.MessageContent{Parts: parts}
+}
+
+// chatResponseToMessages converts a unified response into a Messages API response
+func chatResponseToMessages(resp *models.ChatCompletionResponse) *models.AnthropicResponse {
+	message := &models.AnthropicResponse{
+		ID:      resp.ID,
+		Type:    "message",
+		Role:    "assistant",
+		Model:   resp.Model,
+		Content: []models.AnthropicContent{},
+		Usage: models.AnthropicUsage{
+			InputTokens:  resp.Usage.InputTokens,
+			OutputTokens: resp.Usage.OutputTokens,
+		},
+	}
+
+	if len(resp.Choices) == 0 {
+		return message
+	}
+
+	choice := resp.Choices[0]
+	if text := choice.Message.Content.String(); text != "" {
+		message.Content = append(message.Content, models.AnthropicContent{Type: "text", Text: text})
+	}
+	for _, call := range choice.Message.ToolCalls {
+		message.Content = append(message.Content, models.AnthropicContent{
+			Type:  "tool_use",
+			ID:    call.ID,
+			Name:  call.Function.Name,
+			Input: toolArguments(call.Function.Arguments),
+		})
+	}
+	message.StopReason = messagesStopReason(choice.FinishReason)
+
+	return message
+}
+
+// messagesStopReason maps OpenAI finish reasons back onto Anthropic stop reasons
+func messagesStopReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls":
+		return "tool_use"
+	default:
+		return "end_turn"
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"llm-inferra/internal/models"
+)
+
+// messagesStreamTranslator converts the OpenAI chat.completion.chunk stream of a non-Anthropic
+// provider into Messages streaming events: message_start, content_block_start/delta/stop for
+// each text and tool_use block, then message_delta and message_stop
+type messagesStreamTranslator struct {
+	id    string
+	model string
+
+	started    bool
+	failed     bool
+	nextBlock  int
+	openBlock  int // index of the open content block, -1 when none is open
+	textBlock  int // index of the open text block, -1 when the open block is not text
+	toolBlocks map[int]int
+
+	stopReason   string
+	inputTokens  int
+	outputTokens int
+}
+
+func newMessagesStreamTranslator(model string) *messagesStreamTranslator {
+	return &messagesStreamTranslator{
+		model:      model,
+		openBlock:  -1,
+		textBlock:  -1,
+		toolBlocks: make(map[int]int),
+	}
+}
+
+// translateMessagesStream wraps a unified chunk stream into a Messages event stream. Once done is
+// closed (the client went away) events are dropped, the unified stream is still read to its end.
+func translateMessagesStream(stream <-chan []byte, model string, done <-chan struct{}) <-chan []byte {
+	events := make(chan []byte, 100)
+	go func() {
+		defer close(events)
+
+		clientGone := false
+		send := func(batch [][]byte) {
+			for _, event := range batch {
+				if clientGone {
+					return
+				}
+				select {
+				case events <- event:
+				case <-done:
+					clientGone = true
+				}
+			}
+		}
+
+		t := newMessagesStreamTranslator(model)
+		for data := range stream {
+			send(t.translate(data))
+		}
+		send(t.finish())
+	}()
+	return events
+}
+
+// messagesEvent encodes a Messages streaming event
+func messagesEvent(eventType string, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", eventType, string(data)))
+}
+
+// translate processes the SSE events of one stream message
+func (t *messagesStreamTranslator) translate(data []byte) [][]byte {
+	var out [][]byte
+	for _, line := range strings.Split(string(data), "\n") {
+		payload, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		payload = strings.TrimSpace(payload)
+		if !ok || payload == "" || payload == "[DONE]" || t.failed {
+			continue
+		}
+
+		var chunk struct {
+			ID      string          `json:"id"`
+			Model   string          `json:"model"`
+			Error   json.RawMessage `json:"error"`
+			Choices []struct {
+				Delta struct {
+					Content   *string           `json:"content"`
+					ToolCalls []models.ToolCall `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *models.OpenAIUsage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			continue
+		}
+
+		if len(chunk.Error) > 0 {
+			t.failed = true
+			out = append(out, messagesEvent("error", map[string]interface{}{
+				"type":  "error",
+				"error": map[string]string{"type": "api_error", "message": streamErrorMessage(chunk.Error)},
+			}))
+			continue
+		}
+
+		if chunk.ID != "" && t.id == "" {
+			t.id = chunk.ID
+		}
+		if chunk.Model != "" {
+			t.model = chunk.Model
+		}
+		out = append(out, t.start()...)
+
+		if chunk.Usage != nil {
+			t.inputTokens = chunk.Usage.PromptTokens
+			t.outputTokens = chunk.Usage.CompletionTokens
+		}
+
+		// Messages has a single candidate, only the first choice is streamed
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+
+		if choice.Delta.Content != nil && *choice.Delta.Content != "" {
+			if t.textBlock == -1 {
+				out = append(out, t.openContentBlock(map[string]interface{}{"type": "text", "text": ""})...)
+				t.textBlock = t.openBlock
+			}
+			out = append(out, messagesEvent("content_block_delta", map[string]interface{}{
+				"type":  "content_block_delta",
+				"index": t.textBlock,
+				"delta": map[string]string{"type": "text_delta", "text": *choice.Delta.Content},
+			}))
+		}
+
+		for _, call := range choice.Delta.ToolCalls {
+			toolIndex := len(t.toolBlocks)
+			if call.Index != nil {
+				toolIndex = *call.Index
+			}
+
+			block, ok := t.toolBlocks[toolIndex]
+			if !ok {
+				out = append(out, t.openContentBlock(map[string]interface{}{
+					"type":  "tool_use",
+					"id":    call.ID,
+					"name":  call.Function.Name,
+					"input": map[string]interface{}{},
+				})...)
+				block = t.openBlock
+				t.toolBlocks[toolIndex] = block
+			}
+			if call.Function.Arguments != "" {
+				out = append(out, messagesEvent("content_block_delta", map[string]interface{}{
+					"type":  "content_block_delta",
+					"index": block,
+					"delta": map[string]string{"type": "input_json_delta", "partial_json": call.Function.Arguments},
+				}))
+			}
+		}
+
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			t.stopReason = messagesStopReason(*choice.FinishReason)
+		}
+	}
+	return out
+}
+
+// start emits message_start before the first content of the stream
+func (t *messagesStreamTranslator) start() [][]byte {
+	if t.started {
+		return nil
+	}
+	t.started = true
+
+	return [][]byte{messagesEvent("message_start", map[string]interface{}{
+		"type": "message_start",
+		"message": map[string]interface{}{
+			"id":            t.id,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         t.model,
+			"content":       []interface{}{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         map[string]int{"input_tokens": t.inputTokens, "output_tokens": 0},
+		},
+	})}
+}
+
+// openContentBlock closes the open block and starts a new one
+func (t *messagesStreamTranslator) openContentBlock(block map[string]interface{}) [][]byte {
+	out := t.closeContentBlock()
+	t.openBlock = t.nextBlock
+	t.nextBlock++
+	return append(out, messagesEvent("content_block_start", map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         t.openBlock,
+		"content_block": block,
+	}))
+}
+
+func (t *messagesStreamTranslator) closeContentBlock() [][]byte {
+	if t.openBlock == -1 {
+		return nil
+	}
+	event := messagesEvent("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": t.openBlock,
+	})
+	t.openBlock = -1
+	t.textBlock = -1
+	return [][]byte{event}
+}
+
+// finish closes the message once the unified stream has ended
+func (t *messagesStreamTranslator) finish() [][]byte {
+	if t.failed {
+		return nil
+	}
+
+	out := t.start()
+	out = append(out, t.closeContentBlock()...)
+
+	stopReason := t.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+	out = append(out,
+		messagesEvent("message_delta", map[string]interface{}{
+			"type":  "message_delta",
+			"delta": map[string]interface{}{"stop_reason": stopReason, "stop_sequence": nil},
+			"usage": map[string]int{"input_tokens": t.inputTokens, "output_tokens": t.outputTokens},
+		}),
+		messagesEvent("message_stop", map[string]string{"type": "message_stop"}),
+	)
+	return out
+}
+
+// streamErrorMessage reads the message of a stream error, sent either as a string or as an
+// {"message": ...} object
+func streamErrorMessage(raw json.RawMessage) string {
+	var message string
+	if json.Unmarshal(raw, &message) == nil {
+		return message
+	}
+
+	var object struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(raw, &object) == nil && object.Message != "" {
+		return object.Message
+	}
+	return string(raw)
+}
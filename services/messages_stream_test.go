@@ -0,0 +1,114 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// collectMessagesEvents runs chunks through the translator and returns the event types and payloads
+func collectMessagesEvents(t *testing.T, chunks ...string) ([]string, []map[string]interface{}) {
+	t.Helper()
+
+	stream := make(chan []byte, len(chunks))
+	for _, chunk := range chunks {
+		stream <- []byte("data: " + chunk + "\n\n")
+	}
+	close(stream)
+
+	var types []string
+	var payloads []map[string]interface{}
+	for event := range translateMessagesStream(stream, "gpt-4o", nil) {
+		lines := strings.Split(strings.TrimSpace(string(event)), "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") || !strings.HasPrefix(lines[1], "data: ") {
+			t.Fatalf("malformed event %q", event)
+		}
+
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &payload); err != nil {
+			t.Fatalf("decode event %q: %v", event, err)
+		}
+		types = append(types, strings.TrimPrefix(lines[0], "event: "))
+		payloads = append(payloads, payload)
+	}
+	return types, payloads
+}
+
+func TestMessagesStreamTranslatesText(t *testing.T) {
+	types, payloads := collectMessagesEvents(t,
+		`{"id":"c1","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`,
+		`{"id":"c1","choices":[{"index":0,"delta":{"content":"Hel"}}]}`,
+		`{"id":"c1","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"length"}]}`,
+		`{"id":"c1","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2}}`,
+	)
+
+	want := []string{"message_start", "content_block_start", "content_block_delta", "content_block_delta",
+		"content_block_stop", "message_delta", "message_stop"}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+
+	delta := payloads[5]["delta"].(map[string]interface{})
+	if delta["stop_reason"] != "max_tokens" {
+		t.Errorf("stop_reason = %v, want max_tokens", delta["stop_reason"])
+	}
+	usage := payloads[5]["usage"].(map[string]interface{})
+	if usage["input_tokens"] != float64(5) || usage["output_tokens"] != float64(2) {
+		t.Errorf("usage = %v", usage)
+	}
+}
+
+func TestMessagesStreamTranslatesToolCalls(t *testing.T) {
+	types, payloads := collectMessagesEvents(t,
+		`{"id":"c2","choices":[{"index":0,"delta":{"content":"Let me check."}}]}`,
+		`{"id":"c2","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":""}}]}}]}`,
+		`{"id":"c2","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+		`{"id":"c2","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}`,
+	)
+
+	want := []string{"message_start", "content_block_start", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop"}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+
+	block := payloads[4]["content_block"].(map[string]interface{})
+	if block["type"] != "tool_use" || block["id"] != "call_1" || block["name"] != "weather" || payloads[4]["index"] != float64(1) {
+		t.Errorf("tool block = %v", payloads[4])
+	}
+	if delta := payloads[5]["delta"].(map[string]interface{}); delta["partial_json"] != `{"city":` {
+		t.Errorf("tool delta = %v", delta)
+	}
+	if delta := payloads[8]["delta"].(map[string]interface{}); delta["stop_reason"] != "tool_use" {
+		t.Errorf("stop_reason = %v, want tool_use", delta["stop_reason"])
+	}
+}
+
+func TestMessagesStreamError(t *testing.T) {
+	types, _ := collectMessagesEvents(t,
+		`{"id":"c3","choices":[{"index":0,"delta":{"content":"Hi"}}]}`,
+		`{"error":"connection reset"}`,
+	)
+
+	want := []string{"message_start", "content_block_start", "content_block_delta", "error"}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+}
+
+func TestMessagesStreamStopsForwardingOnDisconnect(t *testing.T) {
+	stream := make(chan []byte)
+	done := make(chan struct{})
+	events := translateMessagesStream(stream, "gpt-4o", done)
+
+	// Nobody reads the events once the client is gone: the input must still be consumed
+	close(done)
+	for i := 0; i < 500; i++ {
+		stream <- []byte(`data: {"id":"c4","choices":[{"index":0,"delta":{"content":"x"}}]}` + "\n\n")
+	}
+	close(stream)
+
+	for range events {
+	}
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"llm-inferra/internal/models"
+)
+
+// maxImageBytes is the largest decoded image accepted by the gateway (the Anthropic per-image limit)
+const maxImageBytes = 5 * 1024 * 1024
+
+// supportedImageMediaTypes are the image formats every vision-capable provider accepts
+var supportedImageMediaTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// requestHasImages reports whether any message carries an image part
+func requestHasImages(req *models.ChatCompletionRequest) bool {
+	for _, msg := range req.Messages {
+		if msg.Content.HasImages() {
+			return true
+		}
+	}
+	return false
+}
+
+// validateContentParts checks part types, image URLs, media types and image sizes
+func validateContentParts(req *models.ChatCompletionRequest) error {
+	for i, msg := range req.Messages {
+		for j, part := range msg.Content.Parts {
+			if err := validateContentPart(part); err != nil {
+				return fmt.Errorf("message %d, content part %d: %w", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateContentPart(part models.ContentPart) error {
+	switch part.Type {
+	case models.ContentPartText:
+		return nil
+	case models.ContentPartImageURL:
+		if part.ImageURL == nil || part.ImageURL.URL == "" {
+			return fmt.Errorf("image_url.url is required")
+		}
+		if mediaType, data, ok := parseDataURL(part.ImageURL.URL); ok {
+			return validateImageData(mediaType, data)
+		}
+		parsed, err := url.Parse(part.ImageURL.URL)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("image_url must be an http(s) URL or a base64 data URL")
+		}
+		return nil
+	case models.ContentPartImage:
+		if part.Source == nil || part.Source.Type != "base64" {
+			return fmt.Errorf("image source must be of type 'base64'")
+		}
+		return validateImageData(part.Source.MediaType, part.Source.Data)
+	default:
+		return fmt.Errorf("unsupported content part type '%s'", part.Type)
+	}
+}
+
+// validateImageData checks the media type and decoded size of a base64 image
+func validateImageData(mediaType, data string) error {
+	if !supportedImageMediaTypes[mediaType] {
+		return fmt.Errorf("unsupported image media type '%s'", mediaType)
+	}
+	if data == "" {
+		return fmt.Errorf("image data cannot be empty")
+	}
+	if base64.StdEncoding.DecodedLen(len(data)) > maxImageBytes {
+		return fmt.Errorf("image exceeds the maximum size of %d bytes", maxImageBytes)
+	}
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		return fmt.Errorf("image data is not valid base64")
+	}
+	return nil
+}
+
+// parseDataURL splits a data:<media type>;base64,<data> URL
+func parseDataURL(rawURL string) (mediaType, data string, ok bool) {
+	if !strings.HasPrefix(rawURL, "data:") {
+		return "", "", false
+	}
+
+	header, data, found := strings.Cut(strings.TrimPrefix(rawURL, "data:"), ",")
+	if !found || !strings.HasSuffix(header, ";base64") {
+		return "", "", false
+	}
+
+	return strings.TrimSuffix(header, ";base64"), data, true
+}
+
+// openAIContent converts content into the OpenAI shape, turning base64 image parts into data URLs
+func openAIContent(content models.MessageContent) interface{} {
+	if content.Parts == nil {
+		return content.Text
+	}
+
+	parts := make([]models.ContentPart, 0, len(content.Parts))
+	for _, part := range content.Parts {
+		if part.Type == models.ContentPartImage && part.Source != nil {
+			part = models.ContentPart{
+				Type: models.ContentPartImageURL,
+				ImageURL: &models.ImageURL{
+					URL: fmt.Sprintf("data:%s;base64,%s", part.Source.MediaType, part.Source.Data),
+				},
+			}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// anthropicContent converts content into Anthropic content blocks, images become image source blocks
+func anthropicContent(content models.MessageContent) interface{} {
+	if content.Parts == nil {
+		return content.Text
+	}
+
+	blocks := make([]map[string]interface{}, 0, len(content.Parts))
+	for _, part := range content.Parts {
+		switch part.Type {
+		case models.ContentPartText:
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": part.Text})
+		case models.ContentPartImage:
+			blocks = append(blocks, map[string]interface{}{
+				"type": "image",
+				"source": map[string]interface{}{
+					"type":       "base64",
+					"media_type": part.Source.MediaType,
+					"data":       part.Source.Data,
+				},
+			})
+		case models.ContentPartImageURL:
+			source := map[string]interface{}{"type": "url", "url": part.ImageURL.URL}
+			if mediaType, data, ok := parseDataURL(part.ImageURL.URL); ok {
+				source = map[string]interface{}{"type": "base64", "media_type": mediaType, "data": data}
+			}
+			blocks = append(blocks, map[string]interface{}{"type": "image", "source": source})
+		}
+	}
+	return blocks
+}
+
+// geminiParts converts content into Gemini parts. Gemini only takes images inline,
+// so remote image URLs are rejected.
+func geminiParts(content models.MessageContent) ([]models.GeminiPart, error) {
+	if content.Parts == nil {
+		return []models.GeminiPart{{Text: content.Text}}, nil
+	}
+
+	parts := make([]models.GeminiPart, 0, len(content.Parts))
+	for _, part := range content.Parts {
+		switch part.Type {
+		case models.ContentPartText:
+			parts = append(parts, models.GeminiPart{Text: part.Text})
+		case models.ContentPartImage:
+			parts = append(parts, models.GeminiPart{InlineData: &models.GeminiBlob{
+				MimeType: part.Source.MediaType,
+				Data:     part.Source.Data,
+			}})
+		case models.ContentPartImageURL:
+			mediaType, data, ok := parseDataURL(part.ImageURL.URL)
+			if !ok {
+				return nil, fmt.Errorf("Gemini only supports base64 images, not remote image URLs")
+			}
+			parts = append(parts, models.GeminiPart{InlineData: &models.GeminiBlob{
+				MimeType: mediaType,
+				Data:     data,
+			}})
+		}
+	}
+	return parts, nil
+}
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"llm-inferra/internal/models"
+)
+
+type OpenAIProvider struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+func NewOpenAIProvider(baseURL string) *OpenAIProvider {
+	return &OpenAIProvider{
+		httpClient: newUpstreamHTTPClient(defaultResponseHeaderTimeout),
+		baseURL:    strings.TrimRight(baseURL, "/"),
+	}
+}
+
+func (op *OpenAIProvider) ValidateRequest(req *models.ChatCompletionRequest) error {
+	if req.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+
+	if len(req.Messages) == 0 {
+		return fmt.Errorf("messages are required")
+	}
+
+	// Validate message roles for OpenAI
+	for i, msg := range req.Messages {
+		if msg.Role != "system" && msg.Role != "user" && msg.Role != "assistant" && msg.Role != "tool" {
+			return fmt.Errorf("message %d: role must be 'system', 'user', 'assistant' or 'tool' for OpenAI", i)
+		}
+		if err := validateToolMessage(i, msg); err != nil {
+			return err
+		}
+	}
+
+	return validateTools(req)
+}
+
+// TransformRequest converts the unified request into the OpenAI chat completions format.
+// OpenAI has no top-level system field, so req.System is sent as a leading system message.
+func (op *OpenAIProvider) TransformRequest(req *models.ChatCompletionRequest) (interface{}, error) {
+	messages := make([]map[string]interface{}, 0, len(req.Messages)+1)
+	if req.System != "" {
+		messages = append(messages, map[string]interface{}{"role": "system", "content": req.System})
+	}
+	for _, msg := range req.Messages {
+		message := map[string]interface{}{
+			"role":    msg.Role,
+			"content": openAIContent(msg.Content),
+		}
+		if len(msg.ToolCalls) > 0 {
+			message["tool_calls"] = msg.ToolCalls
+		}
+		if msg.ToolCallID != "" {
+			message["tool_call_id"] = msg.ToolCallID
+		}
+		if msg.Name != "" {
+			message["name"] = msg.Name
+		}
+		messages = append(messages, message)
+	}
+
+	openaiReq := map[string]interface{}{
+		"model":    req.Model,
+		"messages": messages,
+	}
+
+	// Optional parameters
+	if req.MaxTokens != nil {
+		openaiReq["max_tokens"] = *req.MaxTokens
+	}
+
+	if req.Temperature != nil {
+		openaiReq["temperature"] = *req.Temperature
+	}
+
+	if req.TopP != nil {
+		openaiReq["top_p"] = *req.TopP
+	}
+
+	if req.Stop != nil {
+		openaiReq["stop"] = req.Stop
+	}
+
+	// The deprecated functions/function_call fields are always sent in the tools form
+	if tools := requestTools(req); len(tools) > 0 {
+		for i := range tools {
+			tools[i].Type = "function"
+		}
+		openaiReq["tools"] = tools
+
+		switch mode, name := requestToolChoice(req); mode {
+		case "function":
+			openaiReq["tool_choice"] = map[string]interface{}{
+				"type":     "function",
+				"function": map[string]interface{}{"name": name},
+			}
+		default:
+			openaiReq["tool_choice"] = mode
+		}
+	}
+
+	if req.Stream {
+		openaiReq["stream"] = true
+		// Ask OpenAI to append a final chunk carrying token usage so streamed requests can be billed
+		openaiReq["stream_options"] = map[string]interface{}{
+			"include_usage": true,
+		}
+	}
+
+	return openaiReq, nil
+}
+
+// TransformResponse converts an OpenAI chat completion into the unified response format.
+func (op *OpenAIProvider) TransformResponse(resp interface{}) (*models.ChatCompletionResponse, error) {
+	openaiResp, ok := resp.(*models.OpenAIResponse)
+	if !ok {
+		return nil, fmt.Errorf("invalid response type")
+	}
+
+	response := &models.ChatCompletionResponse{
+		ID:      openaiResp.ID,
+		Model:   openaiResp.Model,
+		Object:  openaiResp.Object,
+		Created: openaiResp.Created,
+		Usage: models.ChatCompletionUsage{
+			InputTokens:      openaiResp.Usage.PromptTokens,
+			OutputTokens:     openaiResp.Usage.CompletionTokens,
+			TotalTokens:      openaiResp.Usage.PromptTokens + openaiResp.Usage.CompletionTokens,
+			PromptTokens:     openaiResp.Usage.PromptTokens,
+			CompletionTokens: openaiResp.Usage.CompletionTokens,
+		},
+	}
+
+	if response.Object == "" {
+		response.Object = "chat.completion"
+	}
+	if response.Created == 0 {
+		response.Created = time.Now().Unix()
+	}
+
+	for _, choice := range openaiResp.Choices {
+		response.Choices = append(response.Choices, models.ChatCompletionChoice(choice))
+	}
+
+	// Mirror the first choice into the content blocks used by Anthropic-style clients
+	if len(openaiResp.Choices) > 0 {
+		first := openaiResp.Choices[0].Message
+		response.Role = first.Role
+		if text := first.Content.String(); text != "" {
+			response.Content = append(response.Content, models.ChatCompletionContent{Type: "text", Text: text})
+		}
+		for _, call := range first.ToolCalls {
+			response.Content = append(response.Content, models.ChatCompletionContent{
+				Type:  "tool_use",
+				ID:    call.ID,
+				Name:  call.Function.Name,
+				Input: toolArguments(call.Function.Arguments),
+			})
+		}
+	}
+
+	return response, nil
+}
+
+func (op *OpenAIProvider) ChatCompletion(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest) (*models.ChatCompletionResponse, error) {
+	// Validate request
+	if err := op.ValidateRequest(req); err != nil {
+		return nil, fmt.Errorf("request validation failed: %w", err)
+	}
+
+	// Transform request for OpenAI API
+	openaiReq, err := op.TransformRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("request transformation failed: %w", err)
+	}
+
+	httpReq, err := op.newHTTPRequest(ctx, "/chat/completions", openaiReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the request
+	httpResp, err := op.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	// Read response body
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Handle non-2xx status codes
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return nil, newUpstreamError(httpResp, respBody)
+	}
+
+	// Parse OpenAI response
+	var openaiResp models.OpenAIResponse
+	if err := json.Unmarshal(respBody, &openaiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Transform to standard response format
+	response, err := op.TransformResponse(&openaiResp)
+	if err != nil {
+		return nil, fmt.Errorf("response transformation failed: %w", err)
+	}
+
+	return response, nil
+}
+
+func (op *OpenAIProvider) StreamChatCompletion(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest) (<-chan []byte, error) {
+	// Set streaming flag
+	req.Stream = true
+
+	// Validate request
+	if err := op.ValidateRequest(req); err != nil {
+		return nil, fmt.Errorf("request validation failed: %w", err)
+	}
+
+	// Transform request for OpenAI API
+	openaiReq, err := op.TransformRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("request transformation failed: %w", err)
+	}
+
+	httpReq, err := op.newHTTPRequest(ctx, "/chat/completions", openaiReq)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("Cache-Control", "no-cache")
+
+	// Make the request
+	httpResp, err := op.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	// Handle non-2xx status codes
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		defer httpResp.Body.Close()
+		respBody, _ := io.ReadAll(httpResp.Body)
+		return nil, newUpstreamError(httpResp, respBody)
+	}
+
+	// Create channel for streaming response
+	streamChan := make(chan []byte, 100)
+
+	go func() {
+		defer close(streamChan)
+		defer httpResp.Body.Close()
+
+		// OpenAI sends one "data: {...}" line per chunk, so a line scanner is enough
+		scanner := bufio.NewScanner(httpResp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+		clientWantsUsage := includeUsage(req)
+		for scanner.Scan() {
+			for _, processedData := range op.processSSELine(scanner.Text(), clientWantsUsage) {
+				streamChan <- processedData
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			// Send error as last message
+			errorMsg := fmt.Sprintf("data: {\"error\": \"%v\"}\n\n", err)
+			streamChan <- []byte(errorMsg)
+		}
+	}()
+
+	return streamChan, nil
+}
+
+// Embeddings calls the /embeddings endpoint. Vectors are always requested as floats,
+// base64 encoding for the client is applied by LLMService.
+func (op *OpenAIProvider) Embeddings(ctx *models.LLMRequestContext, req *models.EmbeddingRequest) (*models.EmbeddingResponse, error) {
+	openaiReq := map[string]interface{}{
+		"model":           req.Model,
+		"input":           []string(req.Input),
+		"encoding_format": models.EmbeddingEncodingFloat,
+	}
+
+	if req.Dimensions != nil {
+		openaiReq["dimensions"] = *req.Dimensions
+	}
+
+	if req.User != "" {
+		openaiReq["user"] = req.User
+	}
+
+	httpReq, err := op.newHTTPRequest(ctx, "/embeddings", openaiReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the request
+	httpResp, err := op.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	// Read response body
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Handle non-2xx status codes
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return nil, newUpstreamError(httpResp, respBody)
+	}
+
+	// Parse OpenAI response
+	var openaiResp struct {
+		Model string `json:"model"`
+		Data  []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Usage models.EmbeddingUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(respBody, &openaiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	response := &models.EmbeddingResponse{
+		Object: "list",
+		Data:   make([]models.EmbeddingData, 0, len(openaiResp.Data)),
+		Model:  openaiResp.Model,
+		Usage:  openaiResp.Usage,
+	}
+	for _, item := range openaiResp.Data {
+		response.Data = append(response.Data, models.EmbeddingData{
+			Object:    "embedding",
+			Index:     item.Index,
+			Embedding: item.Embedding,
+		})
+	}
+
+	return response, nil
+}
+
+// newHTTPRequest builds an authenticated POST to the given endpoint path
+func (op *OpenAIProvider) newHTTPRequest(ctx *models.LLMRequestContext, path string, body interface{}) (*http.Request, error) {
+	// Serialize request
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequest("POST", op.baseURL+path, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	// Set headers
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+upstreamKey(ctx))
+
+	return httpReq, nil
+}
+
+// OpenAIStreamChunk represents a chat.completion.chunk streaming event
+type OpenAIStreamChunk struct {
+	ID      string              `json:"id"`
+	Object  string              `json:"object"`
+	Choices []json.RawMessage   `json:"choices"`
+	Usage   *models.OpenAIUsage `json:"usage,omitempty"`
+}
+
+// processSSELine processes a single SSE line. The trailing usage chunk is turned into a
+// usage_update event, and is also forwarded when the client asked for include_usage.
+func (op *OpenAIProvider) processSSELine(line string, clientWantsUsage bool) [][]byte {
+	if !strings.HasPrefix(line, "data:") {
+		return nil
+	}
+
+	data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+	// Skip empty data or [DONE] markers, the handler terminates the stream itself
+	if data == "" || data == "[DONE]" {
+		return nil
+	}
+
+	var chunk OpenAIStreamChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err == nil && chunk.Usage != nil && len(chunk.Choices) == 0 {
+		events := [][]byte{}
+		if clientWantsUsage {
+			events = append(events, []byte(fmt.Sprintf("data: %s\n\n", data)))
+		}
+		return append(events, usageUpdateEvent(chunk.Usage.PromptTokens, chunk.Usage.CompletionTokens))
+	}
+
+	// Return original data for content chunks
+	return [][]byte{[]byte(fmt.Sprintf("data: %s\n\n", data))}
+}
+
+// Helper function to calculate cost
+func (op *OpenAIProvider) CalculateCost(usage *models.ChatCompletionUsage, model *models.LLMModel) (inputCost, outputCost, totalCost float64) {
+	inputCost = float64(usage.InputTokens) * model.InputCostPer1K / 1000.0
+	outputCost = float64(usage.OutputTokens) * model.OutputCostPer1K / 1000.0
+	totalCost = inputCost + outputCost
+	return
+}
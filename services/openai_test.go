@@ -0,0 +1,230 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"llm-inferra/internal/models"
+)
+
+func testOpenAIContext() *models.LLMRequestContext {
+	return &models.LLMRequestContext{
+		APIKey: &models.APIKey{KeyValue: "sk-test"},
+	}
+}
+
+func testChatRequest() *models.ChatCompletionRequest {
+	return &models.ChatCompletionRequest{
+		Model:    "gpt-4o-mini",
+		Messages: []models.ChatMessage{{Role: "user", Content: models.TextContent("Hello")}},
+		System:   "Be brief",
+	}
+}
+
+func TestOpenAIChatCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("path = %s, want /chat/completions", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-test" {
+			t.Errorf("Authorization = %q", got)
+		}
+
+		var body struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if body.Model != "gpt-4o-mini" || len(body.Messages) != 2 || body.Messages[0].Role != "system" {
+			t.Errorf("unexpected request body: %+v", body)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"id": "chatcmpl-1",
+			"object": "chat.completion",
+			"created": 1700000000,
+			"model": "gpt-4o-mini",
+			"choices": [{"index": 0, "message": {"role": "assistant", "content": "Hi!"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}
+		}`)
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL)
+	response, err := provider.ChatCompletion(testOpenAIContext(), testChatRequest())
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+
+	if response.ID != "chatcmpl-1" || len(response.Choices) != 1 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	if got := response.Choices[0].Message.Content.String(); got != "Hi!" {
+		t.Errorf("content = %q, want Hi!", got)
+	}
+	if len(response.Content) != 1 || response.Content[0].Text != "Hi!" {
+		t.Errorf("content blocks = %+v", response.Content)
+	}
+	usage := response.Usage
+	if usage.InputTokens != 12 || usage.OutputTokens != 3 || usage.TotalTokens != 15 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestOpenAIChatCompletionUpstreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error": {"type": "rate_limit_error", "message": "slow down"}}`)
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL)
+	_, err := provider.ChatCompletion(testOpenAIContext(), testChatRequest())
+
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		t.Fatalf("error = %v, want *UpstreamError", err)
+	}
+	if upstreamErr.StatusCode != http.StatusTooManyRequests || upstreamErr.Type != "rate_limit_error" {
+		t.Errorf("upstream error = %+v", upstreamErr)
+	}
+	if upstreamErr.RetryAfter.Seconds() != 2 {
+		t.Errorf("RetryAfter = %s, want 2s", upstreamErr.RetryAfter)
+	}
+	if !isFallbackError(err) {
+		t.Error("429 should be a fallback error")
+	}
+}
+
+func TestOpenAIChatCompletionValidation(t *testing.T) {
+	provider := NewOpenAIProvider("http://127.0.0.1:0")
+	req := testChatRequest()
+	req.Messages[0].Role = "narrator"
+
+	_, err := provider.ChatCompletion(testOpenAIContext(), req)
+	if err == nil || !strings.Contains(err.Error(), "request validation failed") {
+		t.Fatalf("error = %v, want a validation error", err)
+	}
+}
+
+// streamOpenAI runs a stream against a stand-in serving the given SSE body and collects the events
+func streamOpenAI(t *testing.T, sse string, req *models.ChatCompletionRequest) []string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		// Usage is always requested upstream so streams can be billed
+		if options, _ := body["stream_options"].(map[string]interface{}); options["include_usage"] != true {
+			t.Errorf("stream_options = %v, want include_usage", body["stream_options"])
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sse)
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL)
+	stream, err := provider.StreamChatCompletion(testOpenAIContext(), req)
+	if err != nil {
+		t.Fatalf("StreamChatCompletion: %v", err)
+	}
+
+	var events []string
+	for data := range stream {
+		events = append(events, string(data))
+	}
+	return events
+}
+
+const testOpenAIStream = `data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}
+
+data: {"id":"c1","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":7,"completion_tokens":2,"total_tokens":9}}
+
+data: [DONE]
+
+`
+
+func TestOpenAIStreamUsageExtraction(t *testing.T) {
+	events := streamOpenAI(t, testOpenAIStream, testChatRequest())
+
+	service := &LLMService{}
+	var usage *models.ChatCompletionUsage
+	var content []string
+	for _, event := range events {
+		if strings.Contains(event, "[DONE]") {
+			t.Errorf("[DONE] should be left to the handler: %q", event)
+		}
+		if u := service.extractUsageFromSSE([]byte(event)); u != nil {
+			usage = u
+			continue
+		}
+		if strings.Contains(event, `"usage"`) {
+			t.Errorf("usage chunk forwarded without include_usage: %q", event)
+		}
+		content = append(content, event)
+	}
+
+	if len(content) != 2 {
+		t.Errorf("forwarded %d content chunks, want 2: %q", len(content), content)
+	}
+	if usage == nil {
+		t.Fatal("no usage_update event in the stream")
+	}
+	if usage.InputTokens != 7 || usage.OutputTokens != 2 || usage.TotalTokens != 9 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestOpenAIStreamIncludeUsage(t *testing.T) {
+	req := testChatRequest()
+	req.StreamOptions = &models.StreamOptions{IncludeUsage: true}
+	events := streamOpenAI(t, testOpenAIStream, req)
+
+	service := &LLMService{}
+	var usageChunks, usageUpdates int
+	for _, event := range events {
+		if service.extractUsageFromSSE([]byte(event)) != nil {
+			usageUpdates++
+		} else if strings.Contains(event, `"usage"`) {
+			usageChunks++
+		}
+	}
+
+	if usageChunks != 1 || usageUpdates != 1 {
+		t.Errorf("usage chunks = %d, usage updates = %d, want 1 and 1", usageChunks, usageUpdates)
+	}
+}
+
+func TestOpenAIStreamUpstreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": {"type": "invalid_request_error", "message": "bad key"}}`)
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(server.URL)
+	_, err := provider.StreamChatCompletion(testOpenAIContext(), testChatRequest())
+	if status := upstreamStatus(err); status != http.StatusUnauthorized {
+		t.Fatalf("status = %d (%v), want 401", status, err)
+	}
+	if isFallbackError(err) {
+		t.Error("401 should not be a fallback error")
+	}
+}
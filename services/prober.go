@@ -0,0 +1,298 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"llm-inferra/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultProbeFailureThreshold = 3
+	// Probe history older than this is deleted
+	probeRetention = 7 * 24 * time.Hour
+
+	// Postgres advisory lock held by the instance running a probe round
+	proberLockID = 0x70726f6265 // "probe"
+)
+
+// ProviderProber periodically sends a minimal completion to the cheapest chat model of every
+// provider that has a probe key, records the result in provider_probes, and moves a provider to
+// maintenance after ProbeFailureThreshold failed probes in a row. Providers it put into
+// maintenance (status reason ProviderStatusReasonProbe) are moved back to active once a probe
+// succeeds again. With several instances only the one holding the advisory lock probes a round.
+type ProviderProber struct {
+	db              *gorm.DB
+	llmService      *LLMService
+	providerService *ProviderService
+	interval        time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+func NewProviderProber(db *gorm.DB, llmService *LLMService, providerService *ProviderService, interval time.Duration) *ProviderProber {
+	return &ProviderProber{
+		db:              db,
+		llmService:      llmService,
+		providerService: providerService,
+		interval:        interval,
+	}
+}
+
+// Start runs the probe loop in the background. A zero interval disables probing.
+func (p *ProviderProber) Start() {
+	if p.interval <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	if p.stop != nil {
+		p.mu.Unlock()
+		return
+	}
+	p.stop = make(chan struct{})
+	stop := p.stop
+	p.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			p.ProbeAll()
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the probe loop
+func (p *ProviderProber) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+}
+
+// ProbeAll probes every active or maintenance provider that has a probe key configured, unless
+// another instance is already running a probe round
+func (p *ProviderProber) ProbeAll() {
+	// The transaction only holds the lock, it is released when the round ends
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", proberLockID).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if locked {
+			p.probeAll()
+		}
+		return nil
+	})
+	if err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to take the prober lock: %v\n", err)
+	}
+}
+
+func (p *ProviderProber) probeAll() {
+	var providers []models.Provider
+	err := p.db.Where("status IN ? AND probe_api_key_id IS NOT NULL", []models.ProviderStatus{models.ProviderStatusActive, models.ProviderStatusMaintenance}).
+		Find(&providers).Error
+	if err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to load providers for probing: %v\n", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range providers {
+		wg.Add(1)
+		go func(provider *models.Provider) {
+			defer wg.Done()
+			p.probe(provider)
+		}(&providers[i])
+	}
+	wg.Wait()
+
+	if err := p.db.Where("created_at < ?", time.Now().Add(-probeRetention)).Delete(&models.ProviderProbe{}).Error; err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to prune provider probes: %v\n", err)
+	}
+}
+
+// probe sends one probe request to a provider and acts on the result
+func (p *ProviderProber) probe(provider *models.Provider) {
+	var apiKey models.APIKey
+	if err := p.db.Where("id = ? AND provider_id = ? AND status = ?", *provider.ProbeAPIKeyID, provider.ID, models.APIKeyStatusActive).
+		First(&apiKey).Error; err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Probe key for provider %s not found or inactive\n", provider.Name)
+		return
+	}
+
+	// Cheapest chat model, embedding-only models can't answer a completion
+	var candidates []models.LLMModel
+	if err := p.db.Where("provider_id = ? AND status = ? AND supports_embeddings = ?", provider.ID, models.ModelStatusActive, false).
+		Find(&candidates).Error; err != nil || len(candidates) == 0 {
+		return
+	}
+	model := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if candidate.InputCostPer1K+candidate.OutputCostPer1K < model.InputCostPer1K+model.OutputCostPer1K {
+			model = candidate
+		}
+	}
+
+	adapter, err := p.llmService.providers.Get(provider)
+	if err != nil {
+		return
+	}
+
+	ctx := &models.LLMRequestContext{
+		RequestID: "probe-" + uuid.New().String(),
+		UserID:    apiKey.UserID,
+		APIKeyID:  apiKey.ID,
+		Provider:  provider,
+		Model:     &model,
+		APIKey:    &apiKey,
+		UserAgent: "llm-inferra-prober",
+		StartTime: time.Now(),
+	}
+	maxTokens := 1
+	req := &models.ChatCompletionRequest{
+		Model:     model.ModelID,
+		Messages:  []models.ChatMessage{{Role: "user", Content: models.TextContent("ping")}},
+		MaxTokens: &maxTokens,
+	}
+
+	// Probe with the same upstream credential a customer request would use
+	lease, err := p.llmService.credentials.AcquireFor(ctx)
+	if err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("No upstream credential to probe provider %s: %v\n", provider.Name, err)
+		return
+	}
+	if lease != nil {
+		lease.apply(ctx)
+		defer lease.release()
+	}
+
+	startTime := time.Now()
+	_, err = adapter.ChatCompletion(ctx, req)
+	latency := time.Since(startTime)
+
+	result := &models.ProviderProbe{
+		ProviderID: provider.ID,
+		ModelName:  model.ModelID,
+		Success:    err == nil,
+		LatencyMs:  latency.Milliseconds(),
+		HTTPStatus: 200,
+	}
+	if err != nil {
+		result.ErrorMessage = err.Error()
+		result.HTTPStatus = upstreamStatus(err)
+		// Same failures as the circuit breaker counts, a bad or rate limited probe key says
+		// nothing about the provider
+		result.Unhealthy = isBreakerFailure(err)
+	}
+	if err := p.db.Create(result).Error; err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to record probe for provider %s: %v\n", provider.Name, err)
+	}
+
+	switch {
+	case result.Success:
+		p.markRecovered(provider)
+	case !result.Unhealthy:
+		// TODO: Replace with proper logger
+		fmt.Printf("Probe of provider %s failed without pointing at the provider, status unchanged: %v\n", provider.Name, err)
+	case p.consecutiveFailures(provider):
+		p.markDown(provider)
+	}
+}
+
+// consecutiveFailures reports whether the provider's last ProbeFailureThreshold probes all failed
+// for a reason that points at the provider
+func (p *ProviderProber) consecutiveFailures(provider *models.Provider) bool {
+	threshold := provider.ProbeFailureThreshold
+	if threshold <= 0 {
+		threshold = defaultProbeFailureThreshold
+	}
+
+	var recent []models.ProviderProbe
+	if err := p.db.Where("provider_id = ?", provider.ID).
+		Order("created_at DESC").
+		Limit(threshold).
+		Find(&recent).Error; err != nil || len(recent) < threshold {
+		return false
+	}
+
+	for _, probe := range recent {
+		if !probe.Unhealthy {
+			return false
+		}
+	}
+	return true
+}
+
+// markDown moves an active provider to maintenance and opens its circuit
+func (p *ProviderProber) markDown(provider *models.Provider) {
+	if provider.Status == models.ProviderStatusMaintenance && provider.StatusReason == models.ProviderStatusReasonProbe {
+		// Still down, keep customer traffic away from it
+		p.llmService.breakers.TripProvider(provider)
+		return
+	}
+	if provider.Status != models.ProviderStatusActive {
+		return
+	}
+
+	changed, err := p.providerService.SetStatus(provider.ID, provider.Status, provider.StatusReason,
+		models.ProviderStatusMaintenance, models.ProviderStatusReasonProbe)
+	if err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to move provider %s to maintenance: %v\n", provider.Name, err)
+		return
+	}
+	if !changed {
+		// An admin changed the provider while it was being probed
+		return
+	}
+
+	p.llmService.breakers.TripProvider(provider)
+	// TODO: Replace with proper logger
+	fmt.Printf("Provider %s failed health probes, moved to maintenance\n", provider.Name)
+}
+
+// markRecovered moves a provider the prober put into maintenance back to active
+func (p *ProviderProber) markRecovered(provider *models.Provider) {
+	if provider.Status != models.ProviderStatusMaintenance || provider.StatusReason != models.ProviderStatusReasonProbe {
+		return
+	}
+
+	changed, err := p.providerService.SetStatus(provider.ID, models.ProviderStatusMaintenance, models.ProviderStatusReasonProbe,
+		models.ProviderStatusActive, "")
+	if err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to reactivate provider %s: %v\n", provider.Name, err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	p.llmService.breakers.ResetProvider(provider)
+	// TODO: Replace with proper logger
+	fmt.Printf("Provider %s passed health probes, moved back to active\n", provider.Name)
+}
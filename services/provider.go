@@ -1,20 +1,46 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"llm-inferra/internal/models"
 
 	"gorm.io/gorm"
 )
 
+// ProviderChangeListener is notified after a provider row is created, updated or deleted
+type ProviderChangeListener interface {
+	ProviderChanged(provider *models.Provider)
+	ProviderDeleted(providerID uint)
+}
+
 type ProviderService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	listeners     []ProviderChangeListener
+	cache         *CacheService
+	invalidations *InvalidationBus
 }
 
 func NewProviderService(db *gorm.DB) *ProviderService {
 	return &ProviderService{db: db}
 }
 
+// AddListener registers a listener for provider changes
+func (s *ProviderService) AddListener(listener ProviderChangeListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// SetCache lets provider edits drop the cached keys, which carry their provider
+func (s *ProviderService) SetCache(cache *CacheService) {
+	s.cache = cache
+}
+
+// SetInvalidationBus broadcasts provider changes so other instances reload them too
+func (s *ProviderService) SetInvalidationBus(bus *InvalidationBus) {
+	s.invalidations = bus
+}
+
 func (s *ProviderService) List(offset, limit int) ([]models.Provider, int64, error) {
 	var providers []models.Provider
 	var total int64
@@ -42,26 +68,299 @@ func (s *ProviderService) GetByID(id uint) (*models.Provider, error) {
 }
 
 func (s *ProviderService) Create(req models.CreateProviderRequest) (*models.Provider, error) {
+	if req.Type == models.ProviderCustom {
+		if err := validateCustomBaseURL(req.BaseURL); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateCredentialStrategy(req.CredentialStrategy); err != nil {
+		return nil, err
+	}
+
 	provider := models.Provider{
 		Name:        req.Name,
 		Type:        req.Type,
 		Description: req.Description,
 		BaseURL:     req.BaseURL,
 		APIVersion:  req.APIVersion,
+
+		MaxRetryAttempts:   req.MaxRetryAttempts,
+		RetryBaseDelayMs:   req.RetryBaseDelayMs,
+		RetryMaxDelayMs:    req.RetryMaxDelayMs,
+		RetryBudgetPercent: req.RetryBudgetPercent,
+		CredentialStrategy: req.CredentialStrategy,
+
+		DefaultRateLimit: req.DefaultRateLimit,
+		TokensPerMinute:  req.TokensPerMinute,
 	}
-	err := s.db.Create(&provider).Error
-	return &provider, err
+	if err := s.db.Create(&provider).Error; err != nil {
+		return &provider, err
+	}
+
+	s.notifyChanged(&provider)
+	return &provider, nil
 }
 
 func (s *ProviderService) Update(id uint, provider *models.Provider) error {
-	//var provider models.Provider
+	if provider.Type == models.ProviderCustom {
+		if err := validateCustomBaseURL(provider.BaseURL); err != nil {
+			return err
+		}
+	}
+	if err := validateCredentialStrategy(provider.CredentialStrategy); err != nil {
+		return err
+	}
+
+	// The ID comes from the route, not the request body
+	provider.ID = id
+
+	// The status reason is kept while the status is unchanged; a status set by an admin has none
+	var current models.Provider
+	if err := s.db.Select("status", "status_reason").First(&current, id).Error; err != nil {
+		return fmt.Errorf("provider not found: %d", id)
+	}
+	provider.StatusReason = ""
+	if provider.Status == current.Status {
+		provider.StatusReason = current.StatusReason
+	}
+
 	if err := s.db.Save(provider).Error; err != nil {
 		return fmt.Errorf("failed to update provider: %w", err)
 	}
 
+	s.notifyChanged(provider)
+	s.invalidateKeys(id)
 	return nil
 }
 
+// SetStatus moves a provider from one status to another, recording who made the change. It only
+// applies while the provider still has the expected status and reason, so a status an admin has
+// set in the meantime is left alone. Reports whether the status was changed.
+func (s *ProviderService) SetStatus(id uint, from models.ProviderStatus, fromReason string, to models.ProviderStatus, reason string) (bool, error) {
+	result := s.db.Model(&models.Provider{}).
+		Where("id = ? AND status = ? AND status_reason = ?", id, from, fromReason).
+		Updates(map[string]interface{}{"status": to, "status_reason": reason})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update provider status: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	s.reload(id)
+	s.invalidations.PublishProvider(id)
+	s.invalidateKeys(id)
+	return true, nil
+}
+
 func (s *ProviderService) Delete(id uint) error {
-	return s.db.Delete(&models.Provider{}, id).Error
+	if err := s.db.Delete(&models.Provider{}, id).Error; err != nil {
+		return err
+	}
+
+	for _, listener := range s.listeners {
+		listener.ProviderDeleted(id)
+	}
+	s.invalidations.PublishProvider(id)
+	s.invalidateKeys(id)
+	return nil
+}
+
+func (s *ProviderService) notifyChanged(provider *models.Provider) {
+	for _, listener := range s.listeners {
+		listener.ProviderChanged(provider)
+	}
+	s.invalidations.PublishProvider(provider.ID)
+}
+
+// reload notifies the listeners of this instance of a provider's current row, or of its deletion
+// once it is gone. Used for changes made by other instances and for credential pool edits.
+func (s *ProviderService) reload(providerID uint) {
+	var provider models.Provider
+	err := s.db.First(&provider, providerID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		for _, listener := range s.listeners {
+			listener.ProviderDeleted(providerID)
+		}
+		return
+	}
+	if err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to reload provider %d: %v\n", providerID, err)
+		return
+	}
+
+	for _, listener := range s.listeners {
+		listener.ProviderChanged(&provider)
+	}
+}
+
+// invalidateKeys drops the cached keys of a provider so its new settings and status apply at once
+func (s *ProviderService) invalidateKeys(providerID uint) {
+	if s.cache == nil {
+		return
+	}
+
+	var keyHashes []string
+	if err := s.db.Model(&models.APIKey{}).Where("provider_id = ?", providerID).Pluck("key_hash", &keyHashes).Error; err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to load API keys of provider %d: %v\n", providerID, err)
+		return
+	}
+
+	keys := make([]string, 0, len(keyHashes))
+	for _, keyHash := range keyHashes {
+		keys = append(keys, fmt.Sprintf("api_key:%s", keyHash))
+	}
+	if err := s.cache.InvalidateBatch(context.Background(), keys); err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to invalidate cached API keys: %v\n", err)
+	}
+}
+
+// GetModelFallbacks returns a model's fallback chain in the order it is tried
+func (s *ProviderService) GetModelFallbacks(modelID uint) ([]models.ModelFallback, error) {
+	var fallbacks []models.ModelFallback
+	err := s.db.Preload("FallbackModel.Provider").
+		Where("model_id = ?", modelID).
+		Order("priority ASC").
+		Find(&fallbacks).Error
+	return fallbacks, err
+}
+
+// SetModelFallbacks replaces a model's fallback chain with the given models, in order
+func (s *ProviderService) SetModelFallbacks(modelID uint, fallbackModelIDs []uint) ([]models.ModelFallback, error) {
+	var model models.LLMModel
+	if err := s.db.First(&model, modelID).Error; err != nil {
+		return nil, fmt.Errorf("model not found: %d", modelID)
+	}
+
+	seen := make(map[uint]bool, len(fallbackModelIDs))
+	for _, id := range fallbackModelIDs {
+		if id == modelID {
+			return nil, fmt.Errorf("invalid fallback chain: a model cannot fall back to itself")
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("invalid fallback chain: model %d is listed more than once", id)
+		}
+		seen[id] = true
+
+		var fallbackModel models.LLMModel
+		if err := s.db.First(&fallbackModel, id).Error; err != nil {
+			return nil, fmt.Errorf("fallback model not found: %d", id)
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("model_id = ?", modelID).Delete(&models.ModelFallback{}).Error; err != nil {
+			return err
+		}
+		for i, id := range fallbackModelIDs {
+			fallback := models.ModelFallback{
+				ModelID:         modelID,
+				FallbackModelID: id,
+				Priority:        i,
+			}
+			if err := tx.Create(&fallback).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save fallback chain: %w", err)
+	}
+
+	return s.GetModelFallbacks(modelID)
+}
+
+// validateCredentialStrategy accepts the known strategies, and empty for the default
+func validateCredentialStrategy(strategy models.CredentialStrategy) error {
+	switch strategy {
+	case "", models.CredentialWeightedRoundRobin, models.CredentialLeastInFlight, models.CredentialSkipRateLimited:
+		return nil
+	}
+	return fmt.Errorf("invalid credential strategy: %s", strategy)
+}
+
+// ListCredentials returns a provider's credential pool with per-credential usage
+func (s *ProviderService) ListCredentials(providerID uint) ([]models.ProviderCredential, error) {
+	var credentials []models.ProviderCredential
+	err := s.db.Where("provider_id = ?", providerID).Order("id ASC").Find(&credentials).Error
+	return credentials, err
+}
+
+// CreateCredential adds an upstream API key to a provider's credential pool
+func (s *ProviderService) CreateCredential(providerID uint, req models.CreateCredentialRequest) (*models.ProviderCredential, error) {
+	provider, err := s.GetByID(providerID)
+	if err != nil {
+		return nil, fmt.Errorf("provider not found: %d", providerID)
+	}
+	if req.Weight < 0 {
+		return nil, fmt.Errorf("invalid credential weight: %d", req.Weight)
+	}
+
+	credential := models.ProviderCredential{
+		ProviderID: providerID,
+		Name:       req.Name,
+		KeyValue:   req.KeyValue,
+		Weight:     req.Weight,
+	}
+	if err := s.db.Create(&credential).Error; err != nil {
+		return nil, fmt.Errorf("failed to create credential: %w", err)
+	}
+
+	s.notifyChanged(provider)
+	return &credential, nil
+}
+
+// UpdateCredential changes a pooled credential's name, key, weight or status
+func (s *ProviderService) UpdateCredential(providerID, credentialID uint, req models.UpdateCredentialRequest) (*models.ProviderCredential, error) {
+	var credential models.ProviderCredential
+	if err := s.db.Where("id = ? AND provider_id = ?", credentialID, providerID).First(&credential).Error; err != nil {
+		return nil, fmt.Errorf("credential not found: %d", credentialID)
+	}
+
+	if req.Name != nil {
+		credential.Name = *req.Name
+	}
+	if req.KeyValue != nil {
+		credential.KeyValue = *req.KeyValue
+	}
+	if req.Weight != nil {
+		if *req.Weight <= 0 {
+			return nil, fmt.Errorf("invalid credential weight: %d", *req.Weight)
+		}
+		credential.Weight = *req.Weight
+	}
+	if req.Status != nil {
+		if *req.Status != models.CredentialStatusActive && *req.Status != models.CredentialStatusInactive {
+			return nil, fmt.Errorf("invalid credential status: %s", *req.Status)
+		}
+		credential.Status = *req.Status
+	}
+
+	// Saved as a struct so the key goes through the encrypted serializer
+	if err := s.db.Select("name", "key_value", "weight", "status").Updates(&credential).Error; err != nil {
+		return nil, fmt.Errorf("failed to update credential: %w", err)
+	}
+
+	s.reload(providerID)
+	s.invalidations.PublishProvider(providerID)
+	return &credential, nil
+}
+
+// DeleteCredential removes a credential from a provider's pool
+func (s *ProviderService) DeleteCredential(providerID, credentialID uint) error {
+	result := s.db.Where("id = ? AND provider_id = ?", credentialID, providerID).Delete(&models.ProviderCredential{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete credential: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("credential not found: %d", credentialID)
+	}
+
+	s.reload(providerID)
+	s.invalidations.PublishProvider(providerID)
+	return nil
 }
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"llm-inferra/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Default upstream endpoints used when a provider row leaves BaseURL/APIVersion empty
+const (
+	defaultAnthropicBaseURL    = "https://api.anthropic.com/v1"
+	defaultAnthropicAPIVersion = "2023-06-01"
+	defaultOpenAIBaseURL       = "https://api.openai.com/v1"
+	defaultGeminiBaseURL       = "https://generativelanguage.googleapis.com/v1"
+)
+
+// ProviderRegistry holds one adapter per provider row, keyed by provider ID, so that
+// several providers of the same type (e.g. a corporate proxy and the direct API) can coexist.
+// It listens to ProviderService changes and rebuilds adapters without a restart.
+type ProviderRegistry struct {
+	mu       sync.RWMutex
+	adapters map[uint]models.LLMProvider
+	// HTTP clients of the adapters, whose idle connections are closed when they are replaced
+	clients map[uint]*http.Client
+	// Retry budgets outlive adapter rebuilds, a flapping provider must not get its retries back
+	retryBudgets map[uint]*retryBudget
+}
+
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{
+		adapters:     make(map[uint]models.LLMProvider),
+		clients:      make(map[uint]*http.Client),
+		retryBudgets: make(map[uint]*retryBudget),
+	}
+}
+
+// LoadAll builds adapters for every provider row in the database
+func (r *ProviderRegistry) LoadAll(db *gorm.DB) error {
+	var providers []models.Provider
+	if err := db.Find(&providers).Error; err != nil {
+		return fmt.Errorf("failed to load providers: %w", err)
+	}
+
+	for i := range providers {
+		if err := r.Rebuild(&providers[i]); err != nil {
+			// TODO: Replace with proper logger
+			fmt.Printf("Failed to build adapter for provider %s: %v\n", providers[i].Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Get returns the adapter for the provider row, building it on first use
+func (r *ProviderRegistry) Get(provider *models.Provider) (models.LLMProvider, error) {
+	r.mu.RLock()
+	adapter, exists := r.adapters[provider.ID]
+	r.mu.RUnlock()
+	if exists {
+		return adapter, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Another request may have built it while we were waiting for the lock
+	if adapter, exists := r.adapters[provider.ID]; exists {
+		return adapter, nil
+	}
+
+	adapter, httpClient, err := buildProviderAdapter(provider, r.retryBudget(provider.ID))
+	if err != nil {
+		return nil, err
+	}
+	r.set(provider.ID, adapter, httpClient)
+	return adapter, nil
+}
+
+// Rebuild replaces the adapter for the provider row with one built from its current configuration
+func (r *ProviderRegistry) Rebuild(provider *models.Provider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	adapter, httpClient, err := buildProviderAdapter(provider, r.retryBudget(provider.ID))
+	if err != nil {
+		r.remove(provider.ID)
+		return err
+	}
+	r.set(provider.ID, adapter, httpClient)
+	return nil
+}
+
+// Remove drops the adapter for the provider ID
+func (r *ProviderRegistry) Remove(providerID uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(providerID)
+}
+
+// set stores a provider's adapter, closing the idle connections of the one it replaces.
+// Callers hold r.mu.
+func (r *ProviderRegistry) set(providerID uint, adapter models.LLMProvider, httpClient *http.Client) {
+	if previous, ok := r.clients[providerID]; ok && previous != httpClient {
+		previous.CloseIdleConnections()
+	}
+	r.adapters[providerID] = adapter
+	r.clients[providerID] = httpClient
+}
+
+// remove drops a provider's adapter and closes its idle connections. Callers hold r.mu.
+func (r *ProviderRegistry) remove(providerID uint) {
+	if previous, ok := r.clients[providerID]; ok {
+		previous.CloseIdleConnections()
+	}
+	delete(r.adapters, providerID)
+	delete(r.clients, providerID)
+}
+
+// retryBudget returns the provider's retry budget, created on first use. Callers hold r.mu.
+func (r *ProviderRegistry) retryBudget(providerID uint) *retryBudget {
+	budget, ok := r.retryBudgets[providerID]
+	if !ok {
+		budget = newRetryBudget(defaultRetryBudgetPercent)
+		r.retryBudgets[providerID] = budget
+	}
+	return budget
+}
+
+// ProviderChanged implements ProviderChangeListener
+func (r *ProviderRegistry) ProviderChanged(provider *models.Provider) {
+	if err := r.Rebuild(provider); err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to rebuild adapter for provider %s: %v\n", provider.Name, err)
+	}
+}
+
+// ProviderDeleted implements ProviderChangeListener
+func (r *ProviderRegistry) ProviderDeleted(providerID uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(providerID)
+	delete(r.retryBudgets, providerID)
+}
+
+// buildProviderAdapter creates the adapter for a provider row from its BaseURL/APIVersion, with
+// the HTTP client it calls upstream through
+func buildProviderAdapter(provider *models.Provider, budget *retryBudget) (models.LLMProvider, *http.Client, error) {
+	var adapter models.LLMProvider
+	var httpClient *http.Client
+
+	switch provider.Type {
+	case models.ProviderAnthropic:
+		anthropic := NewAnthropicProvider(
+			valueOrDefault(provider.BaseURL, defaultAnthropicBaseURL),
+			valueOrDefault(provider.APIVersion, defaultAnthropicAPIVersion),
+		)
+		adapter, httpClient = anthropic, anthropic.httpClient
+	case models.ProviderOpenAI:
+		openai := NewOpenAIProvider(valueOrDefault(provider.BaseURL, defaultOpenAIBaseURL))
+		adapter, httpClient = openai, openai.httpClient
+	case models.ProviderGoogle:
+		gemini := NewGeminiProvider(valueOrDefault(provider.BaseURL, defaultGeminiBaseURL))
+		adapter, httpClient = gemini, gemini.httpClient
+	case models.ProviderCustom:
+		custom, err := NewCustomProvider(provider.BaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		adapter, httpClient = custom, custom.httpClient
+	default:
+		return nil, nil, fmt.Errorf("provider %s not supported", provider.Type)
+	}
+
+	// Retries happen below the adapters, at the HTTP round-trip level
+	httpClient.Transport = newRetryTransport(httpClient.Transport, newRetryPolicy(provider, budget))
+
+	return adapter, httpClient, nil
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"llm-inferra/internal/models"
+)
+
+// Requests and tokens per minute are counted in fixed one-minute windows, started by the first
+// request of each scope
+const rateLimitWindow = time.Minute
+
+// rateWindow is the usage of one provider, model, user or key in the current window
+type rateWindow struct {
+	start    time.Time
+	requests int
+	tokens   int
+}
+
+// rateLimitScope is a requests and tokens per minute limit to enforce for a request, 0 is unlimited
+type rateLimitScope struct {
+	key      string
+	label    string
+	requests int
+	tokens   int
+}
+
+// RateLimiter enforces requests and tokens per minute at the provider, model, user and API key
+// level. Tokens are admitted on the estimated input tokens and reconciled with the actual usage,
+// output included, once the request has finished.
+type RateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{windows: make(map[string]*rateWindow)}
+}
+
+// rateLimitLease holds the estimated tokens of one admitted request until it is reconciled
+type rateLimitLease struct {
+	limiter  *RateLimiter
+	keys     []string
+	starts   []time.Time
+	estimate int
+	done     bool
+}
+
+// Admit counts the request against every scope that has a limit, rejecting it when one of them
+// has no requests or tokens left in the current window. ctx.RateLimit is set in both cases so the
+// caller can report the limits. It returns nil when no scope has a limit.
+func (l *RateLimiter) Admit(ctx *models.LLMRequestContext, estimatedTokens int) (*rateLimitLease, error) {
+	scopes := rateLimitScopes(ctx)
+	if len(scopes) == 0 {
+		return nil, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	windows := make([]*rateWindow, len(scopes))
+	for i, scope := range scopes {
+		window, ok := l.windows[scope.key]
+		if !ok || !now.Before(window.start.Add(rateLimitWindow)) {
+			window = &rateWindow{start: now}
+			l.windows[scope.key] = window
+		}
+		windows[i] = window
+	}
+
+	for i, scope := range scopes {
+		window := windows[i]
+		reset := window.start.Add(rateLimitWindow).Sub(now)
+
+		if scope.requests > 0 && window.requests+1 > scope.requests {
+			ctx.RateLimit = rateLimitStatus(scopes, windows, now)
+			return nil, fmt.Errorf("request rate limit exceeded for %s: %d requests per minute, resets in %s",
+				scope.label, scope.requests, reset.Round(time.Second))
+		}
+		if scope.tokens > 0 && window.tokens+estimatedTokens > scope.tokens {
+			ctx.RateLimit = rateLimitStatus(scopes, windows, now)
+			if estimatedTokens > scope.tokens {
+				return nil, fmt.Errorf("token rate limit exceeded for %s: request needs about %d tokens, limit is %d tokens per minute",
+					scope.label, estimatedTokens, scope.tokens)
+			}
+			return nil, fmt.Errorf("token rate limit exceeded for %s: %d of %d tokens per minute used, resets in %s",
+				scope.label, window.tokens, scope.tokens, reset.Round(time.Second))
+		}
+	}
+
+	lease := &rateLimitLease{limiter: l, estimate: estimatedTokens}
+	for i, scope := range scopes {
+		windows[i].requests++
+		windows[i].tokens += estimatedTokens
+		lease.keys = append(lease.keys, scope.key)
+		lease.starts = append(lease.starts, windows[i].start)
+	}
+
+	ctx.RateLimit = rateLimitStatus(scopes, windows, now)
+	return lease, nil
+}
+
+// rateLimitScopes returns the limits that apply to the request's provider, model, user and key
+func rateLimitScopes(ctx *models.LLMRequestContext) []rateLimitScope {
+	var scopes []rateLimitScope
+	add := func(key, label string, requests, tokens int) {
+		if requests > 0 || tokens > 0 {
+			scopes = append(scopes, rateLimitScope{key: key, label: label, requests: requests, tokens: tokens})
+		}
+	}
+
+	// The provider's DefaultRateLimit is not a provider-wide bucket, it is the request limit of
+	// each of its keys that don't set their own
+	keyRequests := 0
+	if provider := ctx.Provider; provider != nil {
+		add(fmt.Sprintf("provider:%d", provider.ID), "provider "+provider.Name, 0, provider.TokensPerMinute)
+		keyRequests = provider.DefaultRateLimit
+	}
+	if model := ctx.Model; model != nil {
+		add(fmt.Sprintf("model:%d", model.ID), "model "+model.ModelID, model.RequestsPerMinute, model.TokensPerMinute)
+	}
+	if apiKey := ctx.APIKey; apiKey != nil {
+		if user := apiKey.User; user.ID != 0 {
+			add(fmt.Sprintf("user:%d", user.ID), "user", user.RequestsPerMinute, user.TokensPerMinute)
+		}
+		if apiKey.RequestsPerMinute > 0 {
+			keyRequests = apiKey.RequestsPerMinute
+		}
+		add(fmt.Sprintf("key:%d", apiKey.ID), "API key", keyRequests, apiKey.TokensPerMinute)
+	}
+
+	return scopes
+}
+
+// rateLimitStatus reports the scope with the fewest requests left and the one with the fewest
+// tokens left, like a single OpenAI rate limit
+func rateLimitStatus(scopes []rateLimitScope, windows []*rateWindow, now time.Time) *models.RateLimitStatus {
+	status := &models.RateLimitStatus{}
+	for i, scope := range scopes {
+		window := windows[i]
+		reset := window.start.Add(rateLimitWindow).Sub(now)
+
+		if scope.requests > 0 {
+			remaining := scope.requests - window.requests
+			if remaining < 0 {
+				remaining = 0
+			}
+			if status.LimitRequests == 0 || remaining < status.RemainingRequests {
+				status.LimitRequests = scope.requests
+				status.RemainingRequests = remaining
+				status.ResetRequests = reset
+			}
+		}
+		if scope.tokens > 0 {
+			remaining := scope.tokens - window.tokens
+			if remaining < 0 {
+				remaining = 0
+			}
+			if status.LimitTokens == 0 || remaining < status.RemainingTokens {
+				status.LimitTokens = scope.tokens
+				status.RemainingTokens = remaining
+				status.ResetTokens = reset
+			}
+		}
+	}
+	return status
+}
+
+// Reconcile replaces the estimated tokens with the tokens the request actually used, output
+// included. Tokens are only corrected while the window the request was admitted in is still
+// current. Safe to call on a nil lease.
+func (r *rateLimitLease) Reconcile(actualTokens int) {
+	if r == nil {
+		return
+	}
+
+	r.limiter.mu.Lock()
+	defer r.limiter.mu.Unlock()
+
+	if r.done {
+		return
+	}
+	r.done = true
+
+	for i, key := range r.keys {
+		window, ok := r.limiter.windows[key]
+		if !ok || !window.start.Equal(r.starts[i]) {
+			continue
+		}
+		window.tokens += actualTokens - r.estimate
+		if window.tokens < 0 {
+			window.tokens = 0
+		}
+	}
+}
+
+// Estimate returns the tokens the request was admitted with, 0 for a nil lease
+func (r *rateLimitLease) Estimate() int {
+	if r == nil {
+		return 0
+	}
+	return r.estimate
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"testing"
+
+	"llm-inferra/internal/models"
+)
+
+func TestProviderDefaultRateLimitAppliesPerKey(t *testing.T) {
+	provider := &models.Provider{ID: 1, Name: "openai", DefaultRateLimit: 60, TokensPerMinute: 100000}
+
+	scopesFor := func(apiKey *models.APIKey) map[string]rateLimitScope {
+		scopes := make(map[string]rateLimitScope)
+		for _, scope := range rateLimitScopes(&models.LLMRequestContext{Provider: provider, APIKey: apiKey}) {
+			scopes[scope.key] = scope
+		}
+		return scopes
+	}
+
+	scopes := scopesFor(&models.APIKey{ID: 5})
+	if scope := scopes["provider:1"]; scope.requests != 0 || scope.tokens != 100000 {
+		t.Errorf("provider scope = %+v, want only the token limit", scope)
+	}
+	if scope := scopes["key:5"]; scope.requests != 60 {
+		t.Errorf("key scope = %+v, want the provider default of 60 requests", scope)
+	}
+
+	scopes = scopesFor(&models.APIKey{ID: 6, RequestsPerMinute: 600})
+	if scope := scopes["key:6"]; scope.requests != 600 {
+		t.Errorf("key scope = %+v, want its own 600 requests", scope)
+	}
+}
@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"llm-inferra/internal/models"
+	"llm-inferra/internal/secrets"
+)
+
+// Response cache statuses reported in the X-Inferra-Cache header
+const (
+	ResponseCacheHit         = "hit"
+	ResponseCacheSemanticHit = "semantic-hit"
+	ResponseCacheMiss        = "miss"
+	ResponseCacheBypass      = "bypass"
+)
+
+// responseCacheRequest is how a request uses the response cache
+type responseCacheRequest struct {
+	key string
+	ttl time.Duration
+
+	// Semantic cache scope and embedding of the final user message, set by the lookup when the
+	// key has the semantic cache enabled
+	scope  string
+	vector []float64
+}
+
+// newResponseCacheRequest returns the cache key of a request and how long its response is kept,
+// or nil when the request must not be cached. Only keys that opt in with a response cache TTL
+// are cached, and only deterministic requests (temperature 0). Native Anthropic streams are
+// passed through untranslated and can't be replayed.
+//
+// The key is a digest of the normalized request: the encoded request fields below, with map keys
+// sorted by encoding/json. Entries are scoped to the user, so cached answers never cross accounts.
+func newResponseCacheRequest(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest) *responseCacheRequest {
+	apiKey := ctx.APIKey
+	if apiKey == nil || apiKey.ResponseCacheTTL <= 0 {
+		return nil
+	}
+	if req.Temperature == nil || *req.Temperature != 0 {
+		return nil
+	}
+	if req.StreamFormat == models.StreamFormatAnthropic {
+		return nil
+	}
+
+	normalized := struct {
+		ProviderID   uint                        `json:"provider_id"`
+		Model        string                      `json:"model"`
+		System       string                      `json:"system,omitempty"`
+		Messages     []models.ChatMessage        `json:"messages"`
+		Tools        []models.Tool               `json:"tools,omitempty"`
+		ToolChoice   interface{}                 `json:"tool_choice,omitempty"`
+		Functions    []models.FunctionDefinition `json:"functions,omitempty"`
+		FunctionCall interface{}                 `json:"function_call,omitempty"`
+		MaxTokens    *int                        `json:"max_tokens,omitempty"`
+		Temperature  *float64                    `json:"temperature,omitempty"`
+		TopP         *float64                    `json:"top_p,omitempty"`
+		TopK         *int                        `json:"top_k,omitempty"`
+		Stop         interface{}                 `json:"stop,omitempty"`
+	}{
+		ProviderID:   ctx.Provider.ID,
+		Model:        req.Model,
+		System:       req.System,
+		Messages:     req.Messages,
+		Tools:        req.Tools,
+		ToolChoice:   req.ToolChoice,
+		Functions:    req.Functions,
+		FunctionCall: req.FunctionCall,
+		MaxTokens:    req.MaxTokens,
+		Temperature:  req.Temperature,
+		TopP:         req.TopP,
+		TopK:         req.TopK,
+		Stop:         req.Stop,
+	}
+
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return nil
+	}
+	return &responseCacheRequest{
+		key: fmt.Sprintf("response:%d:%s", ctx.UserID, secrets.Digest(string(data))),
+		ttl: time.Duration(apiKey.ResponseCacheTTL) * time.Second,
+	}
+}
+
+// lookupResponseCache returns the cached response of a request, an identical one first and then
+// a semantically similar one, recording the outcome in ctx.ResponseCacheStatus
+func (s *LLMService) lookupResponseCache(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest, cached *responseCacheRequest) *ResponseCacheEntry {
+	if cached == nil {
+		return nil
+	}
+	if ctx.NoResponseCache {
+		ctx.ResponseCacheStatus = ResponseCacheBypass
+		return nil
+	}
+
+	if entry, found := s.cache.GetResponse(context.Background(), cached.key); found {
+		ctx.ResponseCacheStatus = ResponseCacheHit
+		return entry
+	}
+	if entry := s.lookupSemanticCache(ctx, req, cached); entry != nil {
+		ctx.ResponseCacheStatus = ResponseCacheSemanticHit
+		return entry
+	}
+
+	ctx.ResponseCacheStatus = ResponseCacheMiss
+	return nil
+}
+
+// storeResponseCache keeps a successful response for replay, with the cost it took to produce,
+// and indexes it for the semantic cache
+func (s *LLMService) storeResponseCache(cached *responseCacheRequest, response *models.ChatCompletionResponse, cost float64) {
+	if cached == nil {
+		return
+	}
+	if err := s.cache.SetResponse(context.Background(), cached.key, response, cost, cached.ttl); err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to cache response: %v\n", err)
+		return
+	}
+	if cached.vector != nil {
+		s.vectors.Add(cached.scope, cached.key, cached.vector, cached.ttl)
+	}
+}
+
+// logCachedResponse records a request served from the response cache. It costs nothing, the
+// cost of the original call is recorded as saved.
+func (s *LLMService) logCachedResponse(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest, entry *ResponseCacheEntry) {
+	requestLog, err := s.createRequestLog(ctx, req.Model, req)
+	if err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to create request log: %v\n", err)
+		return
+	}
+
+	responseData, err := json.Marshal(entry.Response)
+	if err != nil {
+		responseData = nil
+	}
+
+	updates := map[string]interface{}{
+		"status":        "completed",
+		"response_data": responseData,
+		"cache_hit":     true,
+		"saved_cost":    entry.Cost,
+		"latency_ms":    time.Since(ctx.StartTime).Milliseconds(),
+		"http_status":   200,
+	}
+	if err := s.db.Model(&models.LLMRequestLog{}).Where("id = ?", requestLog.ID).Updates(updates).Error; err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to update request log: %v\n", err)
+	}
+}
+
+// replayResponseStream streams a cached response as OpenAI chat.completion.chunk events, the way
+// the upstream stream would have delivered it
+func replayResponseStream(response *models.ChatCompletionResponse, req *models.ChatCompletionRequest) <-chan []byte {
+	events := make(chan []byte, len(response.Choices)*4+2)
+
+	chunk := func(index int, delta map[string]interface{}, finishReason *string) []byte {
+		return sseData(map[string]interface{}{
+			"id":      response.ID,
+			"object":  "chat.completion.chunk",
+			"created": response.Created,
+			"model":   response.Model,
+			"choices": []map[string]interface{}{{
+				"index":         index,
+				"delta":         delta,
+				"finish_reason": finishReason,
+			}},
+		})
+	}
+
+	for _, choice := range response.Choices {
+		events <- chunk(choice.Index, map[string]interface{}{"role": "assistant", "content": ""}, nil)
+		if text := choice.Message.Content.String(); text != "" {
+			events <- chunk(choice.Index, map[string]interface{}{"content": text}, nil)
+		}
+		if len(choice.Message.ToolCalls) > 0 {
+			calls := make([]models.ToolCall, len(choice.Message.ToolCalls))
+			for i, call := range choice.Message.ToolCalls {
+				index := i
+				call.Index = &index
+				calls[i] = call
+			}
+			events <- chunk(choice.Index, map[string]interface{}{"tool_calls": calls}, nil)
+		}
+		finishReason := choice.FinishReason
+		events <- chunk(choice.Index, map[string]interface{}{}, &finishReason)
+	}
+
+	if includeUsage(req) {
+		events <- usageChunk(response.ID, response.Model, response.Created, response.Usage.InputTokens, response.Usage.OutputTokens)
+	}
+	close(events)
+	return events
+}
+
+// streamAccumulator rebuilds the complete response from the OpenAI chunks of a stream, so streamed
+// requests can fill the response cache too
+type streamAccumulator struct {
+	id           string
+	model        string
+	created      int64
+	content      strings.Builder
+	toolCalls    []models.ToolCall
+	finishReason string
+	failed       bool
+}
+
+// add processes the SSE events of one forwarded stream message
+func (a *streamAccumulator) add(data []byte) {
+	for _, line := range strings.Split(string(data), "\n") {
+		payload, ok := strings.CutPrefix(strings.TrimSpace(line), "data:")
+		payload = strings.TrimSpace(payload)
+		if !ok || payload == "" || payload == "[DONE]" {
+			continue
+		}
+
+		var chunk struct {
+			ID      string          `json:"id"`
+			Model   string          `json:"model"`
+			Created int64           `json:"created"`
+			Error   json.RawMessage `json:"error"`
+			Choices []struct {
+				Index int `json:"index"`
+				Delta struct {
+					Content   *string           `json:"content"`
+					ToolCalls []models.ToolCall `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			continue
+		}
+		if len(chunk.Error) > 0 {
+			a.failed = true
+			continue
+		}
+		if chunk.ID != "" {
+			a.id = chunk.ID
+		}
+		if chunk.Model != "" {
+			a.model = chunk.Model
+		}
+		if chunk.Created != 0 {
+			a.created = chunk.Created
+		}
+
+		for _, choice := range chunk.Choices {
+			// Only single choice streams are cached
+			if choice.Index != 0 {
+				a.failed = true
+				continue
+			}
+			if choice.Delta.Content != nil {
+				a.content.WriteString(*choice.Delta.Content)
+			}
+			for _, call := range choice.Delta.ToolCalls {
+				a.addToolCall(call)
+			}
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				a.finishReason = *choice.FinishReason
+			}
+		}
+	}
+}
+
+// addToolCall merges a tool call delta: the first one for an index carries the ID and name, the
+// following ones append to the arguments
+func (a *streamAccumulator) addToolCall(delta models.ToolCall) {
+	index := len(a.toolCalls)
+	if delta.Index != nil {
+		index = *delta.Index
+	}
+	for len(a.toolCalls) <= index {
+		a.toolCalls = append(a.toolCalls, models.ToolCall{Type: "function"})
+	}
+
+	call := &a.toolCalls[index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Type != "" {
+		call.Type = delta.Type
+	}
+	if delta.Function.Name != "" {
+		call.Function.Name = delta.Function.Name
+	}
+	call.Function.Arguments += delta.Function.Arguments
+}
+
+// estimatedUsage estimates the usage of a stream the provider reported none for: the request's
+// input estimate and the output streamed so far, or the worst case when no OpenAI chunks were seen
+func (a *streamAccumulator) estimatedUsage(model *models.LLMModel, req *models.ChatCompletionRequest) *models.ChatCompletionUsage {
+	output := estimateTextTokens(a.content.String())
+	for _, call := range a.toolCalls {
+		output += estimateTextTokens(call.Function.Name + call.Function.Arguments)
+	}
+	if output == 0 {
+		output = estimateChatOutputTokens(model, req)
+	}
+
+	input := estimateChatInputTokens(req)
+	return &models.ChatCompletionUsage{
+		InputTokens:      input,
+		OutputTokens:     output,
+		TotalTokens:      input + output,
+		PromptTokens:     input,
+		CompletionTokens: output,
+	}
+}
+
+// response returns the assembled response, nil when the stream failed or did not finish
+func (a *streamAccumulator) response(usage *models.ChatCompletionUsage) *models.ChatCompletionResponse {
+	if a.failed || a.finishReason == "" || usage == nil {
+		return nil
+	}
+
+	message := models.ChatMessage{
+		Role:      "assistant",
+		Content:   models.TextContent(a.content.String()),
+		ToolCalls: a.toolCalls,
+	}
+	response := &models.ChatCompletionResponse{
+		ID:      a.id,
+		Object:  "chat.completion",
+		Created: a.created,
+		Model:   a.model,
+		Role:    message.Role,
+		Usage:   *usage,
+		Choices: []models.ChatCompletionChoice{{Index: 0, Message: message, FinishReason: a.finishReason}},
+	}
+
+	// Mirror the message into the content blocks used by Anthropic-style clients
+	if text := message.Content.String(); text != "" {
+		response.Content = append(response.Content, models.ChatCompletionContent{Type: "text", Text: text})
+	}
+	for _, call := range message.ToolCalls {
+		response.Content = append(response.Content, models.ChatCompletionContent{
+			Type:  "tool_use",
+			ID:    call.ID,
+			Name:  call.Function.Name,
+			Input: toolArguments(call.Function.Arguments),
+		})
+	}
+	return response
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"testing"
+
+	"llm-inferra/internal/models"
+)
+
+func TestStreamEstimatedUsage(t *testing.T) {
+	model := &models.LLMModel{MaxTokens: 4096}
+	req := testChatRequest()
+
+	accumulated := &streamAccumulator{}
+	accumulated.add([]byte(`data: {"id":"c1","choices":[{"index":0,"delta":{"content":"0123456789abcdef"}}]}` + "\n\n"))
+
+	usage := accumulated.estimatedUsage(model, req)
+	if usage.OutputTokens != 4 {
+		t.Errorf("output tokens = %d, want 4 from the streamed text", usage.OutputTokens)
+	}
+	if usage.InputTokens != estimateChatInputTokens(req) || usage.TotalTokens != usage.InputTokens+usage.OutputTokens {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	// Nothing parsed, e.g. a native Anthropic stream: bill the worst case
+	usage = (&streamAccumulator{}).estimatedUsage(model, req)
+	if usage.OutputTokens != estimateChatOutputTokens(model, req) {
+		t.Errorf("output tokens = %d, want the worst case %d", usage.OutputTokens, estimateChatOutputTokens(model, req))
+	}
+}
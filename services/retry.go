@@ -0,0 +1,258 @@
+package services
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"llm-inferra/internal/models"
+)
+
+// Retry policy defaults, used when a provider row leaves the fields unset
+const (
+	defaultRetryMaxAttempts   = 3
+	defaultRetryBaseDelay     = 500 * time.Millisecond
+	defaultRetryMaxDelay      = 10 * time.Second
+	defaultRetryBudgetPercent = 20
+
+	// Retries always allowed regardless of the budget, so a quiet provider can still retry
+	retryBudgetMinTokens = 10
+)
+
+// RetryPolicy controls how failed upstream calls to one provider are retried
+type RetryPolicy struct {
+	MaxAttempts int // total attempts, including the first
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	budget      *retryBudget
+}
+
+// newRetryPolicy builds the retry policy configured on a provider row. The retry budget is the
+// provider's own, carried over from its previous adapter, so rebuilding one doesn't refill it.
+func newRetryPolicy(provider *models.Provider, budget *retryBudget) *RetryPolicy {
+	policy := &RetryPolicy{
+		MaxAttempts: defaultRetryMaxAttempts,
+		BaseDelay:   defaultRetryBaseDelay,
+		MaxDelay:    defaultRetryMaxDelay,
+	}
+	budgetPercent := defaultRetryBudgetPercent
+
+	if provider.MaxRetryAttempts > 0 {
+		policy.MaxAttempts = provider.MaxRetryAttempts
+	}
+	if provider.RetryBaseDelayMs > 0 {
+		policy.BaseDelay = time.Duration(provider.RetryBaseDelayMs) * time.Millisecond
+	}
+	if provider.RetryMaxDelayMs > 0 {
+		policy.MaxDelay = time.Duration(provider.RetryMaxDelayMs) * time.Millisecond
+	}
+	if provider.RetryBudgetPercent > 0 {
+		budgetPercent = provider.RetryBudgetPercent
+	}
+
+	budget.setPercent(budgetPercent)
+	policy.budget = budget
+	return policy
+}
+
+// backoff returns the delay before the given retry (1 for the first retry):
+// exponential backoff with full jitter, capped at MaxDelay
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay << uint(retry-1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// retryBudget limits retries to a share of requests so retries cannot multiply load on a
+// provider that is already failing. Every request deposits percent/100 of a token and
+// every retry withdraws a whole one.
+type retryBudget struct {
+	mu        sync.Mutex
+	tokens    float64
+	maxTokens float64
+	deposit   float64
+}
+
+func newRetryBudget(percent int) *retryBudget {
+	return &retryBudget{
+		tokens:    retryBudgetMinTokens,
+		maxTokens: retryBudgetMinTokens * 10,
+		deposit:   float64(percent) / 100,
+	}
+}
+
+// setPercent changes the share of requests that may be retried, keeping the tokens saved up
+func (b *retryBudget) setPercent(percent int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.deposit = float64(percent) / 100
+}
+
+func (b *retryBudget) recordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += b.deposit
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+}
+
+func (b *retryBudget) tryWithdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// retryTransport retries upstream calls according to a RetryPolicy. It works at the HTTP
+// round-trip level, so a streaming response is only ever retried before it is handed to the
+// adapter, i.e. before any byte has been streamed to the client.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy *RetryPolicy
+}
+
+func newRetryTransport(base http.RoundTripper, policy *RetryPolicy) *retryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryTransport{base: base, policy: policy}
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport, so http.Client's
+// CloseIdleConnections reaches it
+func (t *retryTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.policy.budget.recordRequest()
+
+	for attempt := 1; ; attempt++ {
+		resp, wrote, err := t.send(req)
+
+		if attempt >= t.policy.MaxAttempts || !isRetryableResponse(req, resp, wrote, err) {
+			return resp, err
+		}
+
+		// Wait for the server-requested delay, or back off with jitter
+		delay := t.policy.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header); ok {
+				if retryAfter > t.policy.MaxDelay {
+					// The provider asks for a longer pause than we are willing to wait
+					return resp, err
+				}
+				delay = retryAfter
+			}
+		}
+
+		// The request body has to be replayable
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err
+		}
+
+		if !t.policy.budget.tryWithdraw() {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// send makes one attempt and reports whether the request was written to the connection
+func (t *retryTransport) send(req *http.Request) (*http.Response, bool, error) {
+	var wrote atomic.Bool
+	trace := &httptrace.ClientTrace{
+		// Also called when writing failed part way, the provider may still have read the request
+		WroteRequest: func(httptrace.WroteRequestInfo) { wrote.Store(true) },
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	return resp, wrote.Load(), err
+}
+
+// isRetryableResponse reports whether a failed attempt is safe to retry. Completions are not
+// idempotent and are billed once the provider starts working on them, so only attempts the
+// provider cannot have processed are retried: transport errors before the request was written
+// (dial errors, refused connections, TLS failures), and statuses by which the provider rejects a
+// request without running it (rate limit, service unavailable, overloaded). Timeouts and gateway
+// errors are not retried since the provider may have run the request behind them.
+func isRetryableResponse(req *http.Request, resp *http.Response, wrote bool, err error) bool {
+	if err != nil {
+		// Don't retry when the caller gave up
+		return !wrote && req.Context().Err() == nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusServiceUnavailable,
+		529: // Anthropic overloaded_error
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads the delay requested by the provider from retry-after-ms (OpenAI)
+// or Retry-After, which may hold seconds or an HTTP date
+func parseRetryAfter(header http.Header) (time.Duration, bool) {
+	if value := header.Get("retry-after-ms"); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		delay := time.Until(at)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"llm-inferra/internal/models"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+		budget:      newRetryBudget(defaultRetryBudgetPercent),
+	}
+}
+
+// countingTransport counts the round trips made through it
+type countingTransport struct {
+	base  http.RoundTripper
+	calls atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	return t.base.RoundTrip(req)
+}
+
+// postThroughRetry posts a body to url through a retryTransport and returns the attempts made
+func postThroughRetry(t *testing.T, url string) (*http.Response, int, error) {
+	t.Helper()
+
+	counter := &countingTransport{base: http.DefaultTransport.(*http.Transport).Clone()}
+	client := &http.Client{Transport: newRetryTransport(counter, testRetryPolicy())}
+
+	resp, err := client.Post(url, "application/json", strings.NewReader(`{"model":"gpt-4o"}`))
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return resp, int(counter.calls.Load()), err
+}
+
+func TestRetryStatuses(t *testing.T) {
+	tests := []struct {
+		status   int
+		attempts int
+	}{
+		{http.StatusTooManyRequests, 3},
+		{http.StatusServiceUnavailable, 3},
+		{529, 3},
+		{http.StatusRequestTimeout, 1},
+		{http.StatusBadGateway, 1},
+		{http.StatusGatewayTimeout, 1},
+		{http.StatusInternalServerError, 1},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+
+		resp, attempts, err := postThroughRetry(t, server.URL)
+		server.Close()
+
+		if err != nil || resp.StatusCode != tt.status {
+			t.Errorf("status %d: got %v, %v", tt.status, resp, err)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("status %d: %d attempts, want %d", tt.status, attempts, tt.attempts)
+		}
+	}
+}
+
+func TestRetryConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + listener.Addr().String()
+	listener.Close()
+
+	_, attempts, err := postThroughRetry(t, url)
+	if err == nil {
+		t.Fatal("expected a connection error")
+	}
+	if attempts != 3 {
+		t.Errorf("%d attempts, want 3", attempts)
+	}
+}
+
+func TestNoRetryAfterRequestWritten(t *testing.T) {
+	// The provider reads the request and drops the connection without answering
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	_, attempts, err := postThroughRetry(t, server.URL)
+	if err == nil {
+		t.Fatal("expected a connection error")
+	}
+	if attempts != 1 {
+		t.Errorf("%d attempts, want 1", attempts)
+	}
+}
+
+func TestRetryBudgetSurvivesRebuild(t *testing.T) {
+	registry := NewProviderRegistry()
+	provider := &models.Provider{ID: 1, Type: models.ProviderOpenAI, BaseURL: "http://127.0.0.1:1"}
+
+	if _, err := registry.Get(provider); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	budget := registry.retryBudgets[provider.ID]
+	for budget.tryWithdraw() {
+	}
+
+	if err := registry.Rebuild(provider); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	if registry.retryBudgets[provider.ID] != budget {
+		t.Fatal("Rebuild replaced the provider's retry budget")
+	}
+	if budget.tryWithdraw() {
+		t.Fatal("Rebuild refilled the provider's retry budget")
+	}
+
+	registry.ProviderDeleted(provider.ID)
+	if _, exists := registry.retryBudgets[provider.ID]; exists {
+		t.Fatal("ProviderDeleted kept the provider's retry budget")
+	}
+}
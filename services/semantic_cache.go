@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"llm-inferra/internal/models"
+	"llm-inferra/internal/secrets"
+)
+
+// lookupSemanticCache returns the cached response of a request whose final user message is
+// similar enough to this one, within the same model and system prompt scope. The embedding of
+// the message is kept in cached so the response can be indexed once it has been produced.
+func (s *LLMService) lookupSemanticCache(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest, cached *responseCacheRequest) *ResponseCacheEntry {
+	apiKey := ctx.APIKey
+	if apiKey.SemanticCacheThreshold <= 0 || apiKey.SemanticCacheModel == "" {
+		return nil
+	}
+
+	message := finalUserMessage(req)
+	if message == "" {
+		return nil
+	}
+
+	vector, err := s.embedForCache(ctx, apiKey.SemanticCacheModel, message)
+	if err != nil {
+		// The request goes upstream as a regular miss
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to embed request for semantic cache: %v\n", err)
+		return nil
+	}
+	cached.scope = semanticCacheScope(ctx, req)
+	cached.vector = vector
+
+	key, _, found := s.vectors.Search(cached.scope, vector, apiKey.SemanticCacheThreshold)
+	if !found {
+		return nil
+	}
+
+	entry, found := s.cache.GetResponse(context.Background(), key)
+	if !found {
+		// The response expired or was evicted before its vector
+		s.vectors.Remove(cached.scope, key)
+		return nil
+	}
+	return entry
+}
+
+// semanticCacheScope groups the requests whose final user messages are compared: same user,
+// provider, model and system prompt. Tools are part of the scope too, since they change what an
+// answer looks like, and so is the embedding model, since vectors of different models can't be
+// compared.
+func semanticCacheScope(ctx *models.LLMRequestContext, req *models.ChatCompletionRequest) string {
+	system := req.System
+	for _, message := range req.Messages {
+		if message.Role == "system" {
+			system += "\n" + message.Content.String()
+		}
+	}
+
+	scope := struct {
+		ProviderID     uint          `json:"provider_id"`
+		Model          string        `json:"model"`
+		EmbeddingModel string        `json:"embedding_model"`
+		System         string        `json:"system,omitempty"`
+		Tools          []models.Tool `json:"tools,omitempty"`
+	}{
+		ProviderID:     ctx.Provider.ID,
+		Model:          req.Model,
+		EmbeddingModel: ctx.APIKey.SemanticCacheModel,
+		System:         system,
+		Tools:          requestTools(req),
+	}
+
+	data, err := json.Marshal(scope)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%s", ctx.UserID, secrets.Digest(string(data)))
+}
+
+// finalUserMessage returns the text of the last user message, empty when it has images, which
+// the text embedding would miss
+func finalUserMessage(req *models.ChatCompletionRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		message := req.Messages[i]
+		if message.Role != "user" {
+			continue
+		}
+		if message.Content.HasImages() {
+			return ""
+		}
+		return message.Content.String()
+	}
+	return ""
+}
+
+// embedForCache embeds text with an embedding model of the key's provider. The call is paid for
+// like any other request of the key: it is budgeted, rate limited and logged as a request of its
+// own, and goes out with its own upstream credential lease so the chat request still picks one
+// normally.
+func (s *LLMService) embedForCache(ctx *models.LLMRequestContext, modelName, text string) ([]float64, error) {
+	model, err := s.GetModelByName(ctx.Provider.ID, modelName)
+	if err != nil {
+		return nil, err
+	}
+	if !model.SupportsEmbeddings {
+		return nil, fmt.Errorf("model %s does not support embeddings", model.ModelID)
+	}
+
+	// The embedding gets its own request log next to the chat request's
+	embedCtx := *ctx
+	embedCtx.RequestID = ctx.RequestID + "-semantic-cache"
+	embedCtx.Model = model
+	embedCtx.ResponseCacheStatus = ""
+
+	response, err := s.embed(&embedCtx, model, &models.EmbeddingRequest{
+		Model: model.ModelID,
+		Input: models.EmbeddingInput{text},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("model %s returned no embedding", model.ModelID)
+	}
+	vector, ok := response.Data[0].Embedding.([]float64)
+	if !ok || len(vector) == 0 {
+		return nil, fmt.Errorf("model %s returned no embedding", model.ModelID)
+	}
+	return vector, nil
+}
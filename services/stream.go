@@ -0,0 +1,68 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"llm-inferra/internal/models"
+)
+
+// sseData encodes a value as a single SSE "data:" event
+func sseData(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return []byte(fmt.Sprintf("data: %s\n\n", string(data)))
+}
+
+// usageUpdateEvent builds the internal usage_update event that LLMService strips from the
+// stream and uses to bill the request
+func usageUpdateEvent(inputTokens, outputTokens int) []byte {
+	return sseData(map[string]interface{}{
+		"type": "usage_update",
+		"usage": map[string]int{
+			"input_tokens":  inputTokens,
+			"output_tokens": outputTokens,
+			"total_tokens":  inputTokens + outputTokens,
+		},
+	})
+}
+
+// usageChunk builds the final OpenAI chat.completion.chunk carrying token usage,
+// sent when the client set stream_options.include_usage
+func usageChunk(id, model string, created int64, inputTokens, outputTokens int) []byte {
+	return sseData(map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"model":   model,
+		"choices": []interface{}{},
+		"usage": map[string]int{
+			"prompt_tokens":     inputTokens,
+			"completion_tokens": outputTokens,
+			"total_tokens":      inputTokens + outputTokens,
+		},
+	})
+}
+
+// includeUsage reports whether the client asked for a final usage chunk
+func includeUsage(req *models.ChatCompletionRequest) bool {
+	return req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+}
+
+// validateStreamFormat checks the requested stream format. Native Anthropic passthrough is
+// only possible when the upstream provider is Anthropic.
+func validateStreamFormat(provider *models.Provider, req *models.ChatCompletionRequest) error {
+	switch req.StreamFormat {
+	case "", models.StreamFormatOpenAI:
+		return nil
+	case models.StreamFormatAnthropic:
+		if provider.Type != models.ProviderAnthropic {
+			return fmt.Errorf("request validation failed: stream_format 'anthropic' is not supported for provider %s", provider.Name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("request validation failed: unsupported stream_format '%s'", req.StreamFormat)
+	}
+}
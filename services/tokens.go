@@ -0,0 +1,74 @@
+package services
+
+import (
+	"encoding/json"
+
+	"llm-inferra/internal/models"
+)
+
+// Token estimates used for admission control (budgets, rate limits) before the provider reports
+// real usage. About 4 characters per token, which is close enough for English text and code.
+const (
+	charsPerToken = 4
+	// Per-message overhead for role and formatting tokens
+	messageTokenOverhead = 4
+	// Fixed estimate for an image part, roughly a low detail image
+	imageTokenEstimate = 85
+	// Output estimate when the request sets no max_tokens
+	defaultOutputTokenEstimate = 1024
+)
+
+func estimateTextTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// estimateChatInputTokens estimates the prompt tokens of a chat request
+func estimateChatInputTokens(req *models.ChatCompletionRequest) int {
+	tokens := 0
+	for _, message := range req.Messages {
+		tokens += messageTokenOverhead + estimateTextTokens(message.Content.String())
+		for _, part := range message.Content.Parts {
+			if part.Type != models.ContentPartText {
+				tokens += imageTokenEstimate
+			}
+		}
+		for _, call := range message.ToolCalls {
+			tokens += estimateTextTokens(call.Function.Name) + estimateTextTokens(call.Function.Arguments)
+		}
+	}
+
+	if len(req.Tools) > 0 {
+		if data, err := json.Marshal(req.Tools); err == nil {
+			tokens += estimateTextTokens(string(data))
+		}
+	}
+	return tokens
+}
+
+// estimateChatOutputTokens returns the most output tokens the request is expected to produce
+func estimateChatOutputTokens(model *models.LLMModel, req *models.ChatCompletionRequest) int {
+	tokens := defaultOutputTokenEstimate
+	if req.MaxTokens != nil && *req.MaxTokens > 0 {
+		tokens = *req.MaxTokens
+	}
+	if model.MaxTokens > 0 && tokens > model.MaxTokens {
+		tokens = model.MaxTokens
+	}
+	return tokens
+}
+
+// estimateChatCost estimates the worst-case cost of a chat request on the model
+func estimateChatCost(model *models.LLMModel, req *models.ChatCompletionRequest) float64 {
+	input := estimateChatInputTokens(req)
+	output := estimateChatOutputTokens(model, req)
+	return float64(input)*model.InputCostPer1K/1000.0 + float64(output)*model.OutputCostPer1K/1000.0
+}
+
+// estimateEmbeddingTokens estimates the input tokens of an embeddings request
+func estimateEmbeddingTokens(req *models.EmbeddingRequest) int {
+	tokens := 0
+	for _, text := range req.Input {
+		tokens += estimateTextTokens(text)
+	}
+	return tokens
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"llm-inferra/internal/models"
+)
+
+// requestTools returns the tools declared on the request, folding the deprecated
+// OpenAI "functions" field into the same shape
+func requestTools(req *models.ChatCompletionRequest) []models.Tool {
+	tools := make([]models.Tool, 0, len(req.Tools)+len(req.Functions))
+	tools = append(tools, req.Tools...)
+	for _, fn := range req.Functions {
+		tools = append(tools, models.Tool{Type: "function", Function: fn})
+	}
+	return tools
+}
+
+// requestToolChoice normalizes tool_choice (or the deprecated function_call) into a mode
+// ("auto", "none", "required" or "function") and, for "function", the forced function name
+func requestToolChoice(req *models.ChatCompletionRequest) (mode string, name string) {
+	choice := req.ToolChoice
+	if choice == nil {
+		choice = req.FunctionCall
+	}
+
+	switch v := choice.(type) {
+	case nil:
+		return "auto", ""
+	case string:
+		if v == "any" {
+			return "required", ""
+		}
+		return v, ""
+	case map[string]interface{}:
+		// {"type":"function","function":{"name":"..."}}
+		if fn, ok := v["function"].(map[string]interface{}); ok {
+			if n, ok := fn["name"].(string); ok {
+				return "function", n
+			}
+		}
+		// Deprecated function_call form {"name":"..."}
+		if n, ok := v["name"].(string); ok {
+			return "function", n
+		}
+	}
+
+	return "auto", ""
+}
+
+// requestUsesTools reports whether the request declares tools or carries tool calls/results
+func requestUsesTools(req *models.ChatCompletionRequest) bool {
+	if len(req.Tools) > 0 || len(req.Functions) > 0 {
+		return true
+	}
+	for _, msg := range req.Messages {
+		if len(msg.ToolCalls) > 0 || msg.Role == "tool" {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTools checks the tool definitions and that a forced tool_choice refers to a declared tool
+func validateTools(req *models.ChatCompletionRequest) error {
+	tools := requestTools(req)
+	declared := make(map[string]bool, len(tools))
+
+	for i, tool := range tools {
+		if tool.Type != "" && tool.Type != "function" {
+			return fmt.Errorf("tool %d: unsupported tool type '%s'", i, tool.Type)
+		}
+		if tool.Function.Name == "" {
+			return fmt.Errorf("tool %d: function name is required", i)
+		}
+		if len(tool.Function.Parameters) > 0 && !json.Valid(tool.Function.Parameters) {
+			return fmt.Errorf("tool %d: parameters must be a valid JSON schema", i)
+		}
+		declared[tool.Function.Name] = true
+	}
+
+	mode, name := requestToolChoice(req)
+	switch mode {
+	case "auto", "none":
+	case "required":
+		if len(tools) == 0 {
+			return fmt.Errorf("tool_choice 'required' needs at least one tool")
+		}
+	case "function":
+		if !declared[name] {
+			return fmt.Errorf("tool_choice refers to undeclared function '%s'", name)
+		}
+	default:
+		return fmt.Errorf("unsupported tool_choice '%s'", mode)
+	}
+
+	return nil
+}
+
+// validateToolMessage checks the tool-specific fields of a message. Content may be empty
+// on an assistant message that only carries tool calls.
+func validateToolMessage(i int, msg models.ChatMessage) error {
+	switch msg.Role {
+	case "tool":
+		if msg.ToolCallID == "" {
+			return fmt.Errorf("message %d: tool_call_id is required for tool messages", i)
+		}
+	case "assistant":
+		for j, call := range msg.ToolCalls {
+			if call.ID == "" || call.Function.Name == "" {
+				return fmt.Errorf("message %d: tool call %d must have an id and function name", i, j)
+			}
+		}
+		if len(msg.ToolCalls) > 0 {
+			return nil
+		}
+	}
+
+	if msg.Content.IsEmpty() {
+		return fmt.Errorf("message %d: content cannot be empty", i)
+	}
+	return nil
+}
+
+// toolArguments returns the tool call arguments as a JSON object, defaulting to {}
+func toolArguments(arguments string) json.RawMessage {
+	if arguments == "" || !json.Valid([]byte(arguments)) {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// toolSchema returns the function parameters schema, defaulting to an empty object schema
+func toolSchema(parameters json.RawMessage) json.RawMessage {
+	if len(parameters) == 0 {
+		return json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	return parameters
+}
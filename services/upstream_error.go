@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// UpstreamError is returned when a provider answers with a non-2xx status
+type UpstreamError struct {
+	StatusCode int
+	Type       string        // provider error type, e.g. "overloaded_error" or "rate_limit_error"
+	RetryAfter time.Duration // delay requested by the provider, if any
+	Body       string
+}
+
+func newUpstreamError(resp *http.Response, body []byte) *UpstreamError {
+	upstreamErr := &UpstreamError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	}
+
+	// OpenAI and Anthropic both report {"error": {"type": ...}}
+	var errorBody struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errorBody) == nil {
+		upstreamErr.Type = errorBody.Error.Type
+	}
+
+	if retryAfter, ok := parseRetryAfter(resp.Header); ok {
+		upstreamErr.RetryAfter = retryAfter
+	}
+
+	return upstreamErr
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// upstreamStatus returns the upstream HTTP status carried by err, or 0 if there is none
+func upstreamStatus(err error) int {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.StatusCode
+	}
+	return 0
+}
+
+// isFallbackError reports whether err is worth retrying on another provider:
+// upstream 5xx and 429 responses, timeouts and connection errors
+func isFallbackError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if status := upstreamStatus(err); status != 0 {
+		return status >= 500 || status == http.StatusTooManyRequests
+	}
+
+	// http.Client.Do wraps timeouts and connection failures in *url.Error
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 
 	"llm-inferra/internal/models"
@@ -9,13 +10,19 @@ import (
 )
 
 type UserService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	cache *CacheService
 }
 
 func NewUserService(db *gorm.DB) *UserService {
 	return &UserService{db: db}
 }
 
+// SetCache lets limit changes drop the cached copies of the user's keys, which carry the user
+func (s *UserService) SetCache(cache *CacheService) {
+	s.cache = cache
+}
+
 func (s *UserService) GetByID(id uint) (*models.User, error) {
 	var user models.User
 	if err := s.db.Preload("APIKeys").Preload("UsageLogs").First(&user, id).Error; err != nil {
@@ -76,11 +83,18 @@ func (s *UserService) Update(id uint, req models.UpdateUserRequest) (*models.Use
 	if req.MonthlyCostLimit != nil {
 		user.MonthlyCostLimit = *req.MonthlyCostLimit
 	}
+	if req.RequestsPerMinute != nil {
+		user.RequestsPerMinute = *req.RequestsPerMinute
+	}
+	if req.TokensPerMinute != nil {
+		user.TokensPerMinute = *req.TokensPerMinute
+	}
 
 	if err := s.db.Save(&user).Error; err != nil {
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
+	s.invalidate(user.ID)
 	return &user, nil
 }
 
@@ -88,5 +102,25 @@ func (s *UserService) Delete(id uint) error {
 	if err := s.db.Delete(&models.User{}, id).Error; err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
+
+	s.invalidate(id)
 	return nil
 }
+
+// invalidate drops the cached keys and usage of a user
+func (s *UserService) invalidate(userID uint) {
+	if s.cache == nil {
+		return
+	}
+
+	var apiKeys []models.APIKey
+	if err := s.db.Select("id", "key_hash").Where("user_id = ?", userID).Find(&apiKeys).Error; err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to load API keys of user %d: %v\n", userID, err)
+		return
+	}
+	if err := s.cache.InvalidateUser(context.Background(), userID, apiKeys); err != nil {
+		// TODO: Replace with proper logger
+		fmt.Printf("Failed to invalidate cached user: %v\n", err)
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultVectorIndexEntries bounds the in-process vector index
+const defaultVectorIndexEntries = 10000
+
+// VectorIndex finds cached responses of semantically similar requests. Vectors are grouped in
+// scopes and a search only compares vectors of the same scope. Keys are response cache keys.
+type VectorIndex interface {
+	// Add stores the vector of a cached response, replacing an earlier one with the same key
+	Add(scope, key string, vector []float64, ttl time.Duration)
+	// Search returns the key of the most similar live vector in scope whose cosine similarity is
+	// at least threshold
+	Search(scope string, vector []float64, threshold float64) (key string, similarity float64, found bool)
+	// Remove drops a vector whose cached response is gone
+	Remove(scope, key string)
+}
+
+// MemoryVectorIndex is a brute-force in-process VectorIndex: a search compares every vector of
+// the scope. The oldest vectors are evicted when it is full. Each instance indexes the responses
+// it cached itself, while the responses are shared through CacheService.
+type MemoryVectorIndex struct {
+	mu         sync.Mutex
+	maxEntries int
+	scopes     map[string]map[string]*list.Element
+	order      *list.List // front is the oldest
+}
+
+type vectorEntry struct {
+	scope     string
+	key       string
+	vector    []float64 // normalized to unit length
+	expiresAt time.Time // zero never expires
+}
+
+func NewMemoryVectorIndex(maxEntries int) *MemoryVectorIndex {
+	if maxEntries <= 0 {
+		maxEntries = defaultVectorIndexEntries
+	}
+	return &MemoryVectorIndex{
+		maxEntries: maxEntries,
+		scopes:     make(map[string]map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (x *MemoryVectorIndex) Add(scope, key string, vector []float64, ttl time.Duration) {
+	normalized := normalizeVector(vector)
+	if normalized == nil {
+		return
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if element, ok := x.scopes[scope][key]; ok {
+		x.remove(element)
+	}
+	for x.order.Len() >= x.maxEntries {
+		x.remove(x.order.Front())
+	}
+
+	entries, ok := x.scopes[scope]
+	if !ok {
+		entries = make(map[string]*list.Element)
+		x.scopes[scope] = entries
+	}
+	entries[key] = x.order.PushBack(&vectorEntry{scope: scope, key: key, vector: normalized, expiresAt: expiresAt})
+}
+
+func (x *MemoryVectorIndex) Search(scope string, vector []float64, threshold float64) (string, float64, bool) {
+	normalized := normalizeVector(vector)
+	if normalized == nil {
+		return "", 0, false
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	now := time.Now()
+	var best *vectorEntry
+	bestSimilarity := threshold
+	for _, element := range x.scopes[scope] {
+		entry := element.Value.(*vectorEntry)
+		if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+			x.remove(element)
+			continue
+		}
+		if len(entry.vector) != len(normalized) {
+			continue
+		}
+
+		var similarity float64
+		for i, v := range normalized {
+			similarity += v * entry.vector[i]
+		}
+		if similarity >= bestSimilarity {
+			best = entry
+			bestSimilarity = similarity
+		}
+	}
+
+	if best == nil {
+		return "", 0, false
+	}
+	return best.key, bestSimilarity, true
+}
+
+func (x *MemoryVectorIndex) Remove(scope, key string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if element, ok := x.scopes[scope][key]; ok {
+		x.remove(element)
+	}
+}
+
+func (x *MemoryVectorIndex) remove(element *list.Element) {
+	entry := element.Value.(*vectorEntry)
+	x.order.Remove(element)
+
+	entries := x.scopes[entry.scope]
+	delete(entries, entry.key)
+	if len(entries) == 0 {
+		delete(x.scopes, entry.scope)
+	}
+}
+
+// normalizeVector scales a vector to unit length, so cosine similarity is a dot product. It
+// returns nil for an empty or zero vector.
+func normalizeVector(vector []float64) []float64 {
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm == 0 {
+		return nil
+	}
+
+	norm = math.Sqrt(norm)
+	normalized := make([]float64, len(vector))
+	for i, v := range vector {
+		normalized[i] = v / norm
+	}
+	return normalized
+}